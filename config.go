@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"github.com/leoride/tako-sim/infrastructure"
+	"github.com/leoride/tako-sim/interfaces"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ENV_PREFIX = "TAKOSIM"
+
+// Config is the startup configuration of the simulator. Values are taken from the defaults, the YAML
+// config file, the TAKOSIM_* environment variables and the command line flags, the last one wins.
+type Config struct {
+	TakoEndpoint     string            `yaml:"takoEndpoint"`
+	InterfaceVersion string            `yaml:"interfaceVersion"`
+	Port             int               `yaml:"port"`
+	TenantsFile      string            `yaml:"tenantsFile"`
+	CardsFile        string            `yaml:"cardsFile"`
+	TimezonesFile    string            `yaml:"timezonesFile"`
+	Sink             bool              `yaml:"sink"`
+	SinkCUCM         domain.CUCMAnswer `yaml:"sinkCucm"`
+	SinkMaxMessages  int               `yaml:"sinkMaxMessages"`
+	Persona          string            `yaml:"persona"`
+	CUCMTimeout      time.Duration     `yaml:"cucmTimeout"`
+	SendTimeout      time.Duration     `yaml:"sendTimeout"`
+	Seed             int64             `yaml:"seed"`
+	StateFile        string            `yaml:"stateFile"`
+	ShutdownTimeout  time.Duration     `yaml:"shutdownTimeout"`
+
+	Conflicts domain.ConflictOptions `yaml:"conflicts"`
+	Late      domain.LateOptions     `yaml:"late"`
+	Load      domain.LoadOptions     `yaml:"load"`
+
+	TLS infrastructure.TLSOptions `yaml:"tls"`
+
+	domain.Settings `yaml:",inline"`
+}
+
+func NewConfig() *Config {
+	c := new(Config)
+	c.TakoEndpoint = "http://localhost:8080/tako-fc"
+	c.InterfaceVersion = domain.DEFAULT_INTERFACE_VERSION
+	c.Port = 8282
+	c.SinkMaxMessages = domain.DEFAULT_SINK_MESSAGES
+	c.CUCMTimeout = domain.DEFAULT_CUCM_TIMEOUT
+	c.SendTimeout = interfaces.DEFAULT_SEND_TIMEOUT
+	c.ShutdownTimeout = 30 * time.Second
+	c.Conflicts.SwipeResolution = domain.SWIPE_LAST
+	c.Late.ReminderInterval = 15 * time.Minute
+	c.Load.OrgaNo = "1"
+	c.Load.Rate = 10
+	c.Load.TripDuration = 30 * time.Minute
+	c.Load.LateRatio = 0.1
+	c.Load.NoShowRatio = 0.1
+	c.TLS.Hosts = "localhost,127.0.0.1"
+	c.Settings = domain.DefaultSettings()
+
+	return c
+}
+
+// LoadFile overrides the configuration with the values of a YAML file, unknown keys are refused
+func (c *Config) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("Error reading config file %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// LoadEnv overrides the configuration with the environment, variables are named after the YAML keys:
+// TAKOSIM_PORT, TAKOSIM_TIMINGS_TRIPSTART, TAKOSIM_TIMEZONES_20 and so on
+func (c *Config) LoadEnv() error {
+	return loadEnv(reflect.ValueOf(c).Elem(), ENV_PREFIX)
+}
+
+func loadEnv(v reflect.Value, name string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := strings.Split(field.Tag.Get("yaml"), ",")[0]
+
+			fieldName := name
+			if key != "" {
+				fieldName = name + "_" + strings.ToUpper(key)
+			}
+
+			if err := loadEnv(v.Field(i), fieldName); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case reflect.Map:
+		prefix := name + "_"
+
+		for _, value := range os.Environ() {
+			pair := strings.SplitN(value, "=", 2)
+			if !strings.HasPrefix(pair[0], prefix) {
+				continue
+			}
+
+			k := reflect.New(v.Type().Key()).Elem()
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := setValue(k, strings.TrimPrefix(pair[0], prefix)); err != nil {
+				return fmt.Errorf("Invalid key in %s: %v", pair[0], err)
+			}
+			if err := setValue(e, pair[1]); err != nil {
+				return fmt.Errorf("Invalid value of %s: %v", pair[0], err)
+			}
+
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			v.SetMapIndex(k, e)
+		}
+
+		return nil
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("Invalid value of %s: %v", name, err)
+	}
+
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func (c *Config) Validate() error {
+	if c.TakoEndpoint == "" && !c.Sink {
+		return fmt.Errorf("Tako endpoint is required")
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("Invalid port: %d", c.Port)
+	}
+
+	if domain.GetInterfaceVersion(c.InterfaceVersion) == nil {
+		return fmt.Errorf("Unsupported interface version: %s", c.InterfaceVersion)
+	}
+
+	if !c.SinkCUCM.IsValid() {
+		return fmt.Errorf("Unsupported CUCM answer: %s", c.SinkCUCM)
+	}
+
+	if c.SinkMaxMessages <= 0 {
+		return fmt.Errorf("Sink message limit must be positive: %d", c.SinkMaxMessages)
+	}
+
+	if c.Persona != "" && domain.GetPersonas()[c.Persona] == nil {
+		return fmt.Errorf("Unknown persona: %s", c.Persona)
+	}
+
+	if c.CUCMTimeout <= 0 {
+		return fmt.Errorf("CUCM timeout must be positive: %v", c.CUCMTimeout)
+	}
+
+	if c.SendTimeout <= 0 {
+		return fmt.Errorf("Send timeout must be positive: %v", c.SendTimeout)
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("Shutdown timeout must be positive: %v", c.ShutdownTimeout)
+	}
+
+	if err := c.Conflicts.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Late.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.TLS.Validate(); err != nil {
+		return err
+	}
+
+	return c.Settings.Validate()
+}
+
+func (c *Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+
+	return string(out)
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type RejectionReason string
+
+const (
+	NO_RESERVATION   RejectionReason = "NoReservation"
+	CARD_BLOCKED     RejectionReason = "CardBlocked"
+	CARD_EXPIRED     RejectionReason = "CardExpired"
+	WRONG_ORGA       RejectionReason = "WrongOrga"
+	WRONG_PIN        RejectionReason = "WrongPIN"
+	OUTSIDE_VALIDITY RejectionReason = "OutsideValidityPeriod"
+	CUCM_TIMEOUT     RejectionReason = "CUCMTimeout"
+	AMBIGUOUS        RejectionReason = "AmbiguousReservation"
+
+	//reason of the problem events which are not a rejected access
+	NO_REJECTION RejectionReason = "None"
+)
+
+type ValidityWindow struct {
+	From  time.Time
+	Until time.Time
+}
+
+// Card is a registered smartcard with its access-control rules,
+// cards which are not registered are only checked against the reservations
+type Card struct {
+	AccessDevice
+	Blocked         bool
+	ExpiryDate      time.Time
+	Orgas           []string
+	ValidityWindows []ValidityWindow
+	MaxPINTries     int //wrong PINs in a row before the card is blocked, DEFAULT_PIN_TRIES when not set
+	PINFailures     int
+}
+
+func (c *Card) Validate() error {
+	if c.SmartcardType == "" {
+		return fmt.Errorf("Card configuration requires SmartcardType")
+	}
+
+	return nil
+}
+
+// Check returns why the card may not open the vehicle, an empty reason grants access
+func (c *Card) Check(vd VehicleDevice, now time.Time) RejectionReason {
+	if c.Blocked {
+		return CARD_BLOCKED
+	}
+
+	if !c.ExpiryDate.IsZero() && !now.Before(c.ExpiryDate) {
+		return CARD_EXPIRED
+	}
+
+	if len(c.Orgas) > 0 {
+		allowed := false
+		for _, value := range c.Orgas {
+			if value == vd.OrgaNo {
+				allowed = true
+			}
+		}
+
+		if !allowed {
+			return WRONG_ORGA
+		}
+	}
+
+	if len(c.ValidityWindows) > 0 {
+		valid := false
+		for _, value := range c.ValidityWindows {
+			if !now.Before(value.From) && (value.Until.IsZero() || now.Before(value.Until)) {
+				valid = true
+			}
+		}
+
+		if !valid {
+			return OUTSIDE_VALIDITY
+		}
+	}
+
+	return ""
+}
+
+// cards file format: [{"SmartcardSerialNo": "123", "SmartcardType": "Mifare", "PIN": "1234", "Blocked": true, "ExpiryDate": "2020-01-01T00:00:00Z", "Orgas": ["1234"]}, ...]
+func LoadCards(r io.Reader) ([]*Card, error) {
+	cards := make([]*Card, 0)
+
+	if err := json.NewDecoder(r).Decode(&cards); err != nil {
+		return nil, fmt.Errorf("Error reading cards: %v", err)
+	}
+
+	for _, c := range cards {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cards, nil
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type CommandType string
+
+const (
+	UNLOCK              CommandType = "Unlock"
+	LOCK                CommandType = "Lock"
+	ENABLE_IMMOBILIZER  CommandType = "EnableImmobilizer"
+	DISABLE_IMMOBILIZER CommandType = "DisableImmobilizer"
+
+	DOORS_UNLOCKED       EventName = "CentralLockOpened"
+	DOORS_LOCKED         EventName = "CentralLockClosed"
+	IMMOBILIZER_ENABLED  EventName = "ImmobilizerEnabled"
+	IMMOBILIZER_DISABLED EventName = "ImmobilizerDisabled"
+)
+
+type Command struct {
+	Vehicle       *Vehicle `json:"-"`
+	TechStatus    TaskStatus
+	TaskError     TaskError
+	RequestId     string        `xml:"Body>SendCommand>task>TaskNumber"`
+	VehicleDevice VehicleDevice `xml:"Body>SendCommand>task>Destination"`
+	Type          CommandType   `xml:"Body>SendCommand>task>Command>Type"`
+}
+
+func (ct CommandType) IsValid() bool {
+	switch ct {
+	case UNLOCK, LOCK, ENABLE_IMMOBILIZER, DISABLE_IMMOBILIZER:
+		return true
+	}
+
+	return false
+}
+
+func (ct CommandType) GetEventName() EventName {
+	switch ct {
+	case UNLOCK:
+		return DOORS_UNLOCKED
+	case LOCK:
+		return DOORS_LOCKED
+	case ENABLE_IMMOBILIZER:
+		return IMMOBILIZER_ENABLED
+	default:
+		return IMMOBILIZER_DISABLED
+	}
+}
+
+func (c *Command) GetTechStatus() TaskStatus {
+	return c.TechStatus
+}
+
+func (c *Command) GetTaskError() TaskError {
+	if c.TaskError == "" {
+		return NO_ERROR
+	}
+
+	return c.TaskError
+}
+
+func (c *Command) SetTaskError(te TaskError) {
+	c.TaskError = te
+}
+
+func (c *Command) GetRequestId() string {
+	return c.RequestId
+}
+
+func (c *Command) GetOrgaNo() string {
+	return c.VehicleDevice.OrgaNo
+}
+
+func (c *Command) GetVehicleDevice() VehicleDevice {
+	return c.VehicleDevice
+}
+
+func (c *Command) GetTimezoneCode(e *Environment) int {
+	return e.Settings.DefaultTimezone
+}
+
+func (c *Command) GenerateStatus(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateStatus(e, v, now, RequestI(c))
+}
+
+func (c *Command) SetRequestId(id string) {
+	c.RequestId = id
+}
+
+func (c *Command) String() string {
+	string := ""
+
+	string += fmt.Sprint("Command Request received:") +
+		fmt.Sprint("\n\t") + fmt.Sprint("- Type: ") + fmt.Sprintf("%s", c.Type) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- VehiclePhoneNo: ") + fmt.Sprintf("%s", c.VehicleDevice.VehiclePhoneNo) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- OrgaNo: ") + fmt.Sprintf("%s", c.VehicleDevice.OrgaNo) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- RequestId: ") + fmt.Sprintf("%s", c.RequestId)
+
+	return string
+}
+
+func (c *Command) GenerateCommandEvent(e *Environment, v *InterfaceVersion, now time.Time) string {
+	t := new(Trip)
+	t.Vehicle = c.Vehicle
+	t.VehicleDevice = c.VehicleDevice
+	t.ReservationId = "0"
+	t.OdoStart = c.Vehicle.Odometer
+
+	return t.generateEvent(e, v, now, c.Type.GetEventName(), true)
+}
+
+func (c *Command) GenerateResponse(e *Environment, now time.Time) string {
+	return "<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
+		"\n\t<s:Body>" +
+		"\n\t\t<SendCommandResponse xmlns=\"http://tempuri.org/\">" +
+		"\n\t\t\t<SendCommandResult xmlns:a=\"http://invers.com\" xmlns:i=\"http://www.w3.org/2001/XMLSchema-instance\">" +
+		"\n\t\t\t\t<a:CustomerId>" + e.Settings.Identifiers.CustomerId + "</a:CustomerId>" +
+		"\n\t\t\t\t<a:DataStatus>Sending</a:DataStatus>" +
+		"\n\t\t\t\t<a:TaskError>" + fmt.Sprint(c.GetTaskError()) + "</a:TaskError>" +
+		"\n\t\t\t\t<a:TaskNumber>" + c.GetRequestId() + "</a:TaskNumber>" +
+		"\n\t\t\t\t<a:TaskSendStatus>" + fmt.Sprint(c.GetTechStatus()) + "</a:TaskSendStatus>" +
+		"\n\t\t\t\t<a:Timestamp xmlns:b=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\">" +
+		"\n\t\t\t\t\t<b:Timezone>" + fmt.Sprint(c.GetTimezoneCode(e)) + "</b:Timezone>" +
+		"\n\t\t\t\t\t<b:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
+		"\n\t\t\t\t</a:Timestamp>" +
+		"\n\t\t\t\t<a:UsedCommsystem>Unknown</a:UsedCommsystem>" +
+		"\n\t\t\t</SendCommandResult>" +
+		"\n\t\t</SendCommandResponse>" +
+		"\n\t</s:Body>" +
+		"\n</s:Envelope>"
+}
@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+)
+
+type TaskError string
+type SwipeResolution string
+
+const (
+	NO_ERROR             TaskError = "NoError"
+	RESERVATION_CONFLICT TaskError = "ReservationConflict"
+	FEATURE_NOT_ENABLED  TaskError = "FeatureNotEnabled"
+
+	//a reservation with a running trip is always preferred, the resolution decides between the others
+	SWIPE_LAST         SwipeResolution = "last"
+	SWIPE_FIRST        SwipeResolution = "first"
+	SWIPE_EARLIEST_END SwipeResolution = "earliestEnd"
+	SWIPE_REJECT       SwipeResolution = "reject"
+)
+
+type ConflictOptions struct {
+	RejectOverlaps  bool            `yaml:"rejectOverlaps"`
+	SwipeResolution SwipeResolution `yaml:"swipeResolution"`
+}
+
+func (sr SwipeResolution) IsValid() bool {
+	switch sr {
+	case SWIPE_LAST, SWIPE_FIRST, SWIPE_EARLIEST_END, SWIPE_REJECT:
+		return true
+	}
+
+	return false
+}
+
+func (o *ConflictOptions) Validate() error {
+	if !o.SwipeResolution.IsValid() {
+		return fmt.Errorf("Unsupported swipe resolution: %s", o.SwipeResolution)
+	}
+
+	return nil
+}
+
+// Overlaps tells whether two different reservations book the same vehicle at the same time
+func (r *Reservation) Overlaps(o *Reservation) bool {
+	return r.ReservationId != o.ReservationId &&
+		r.VehicleDevice == o.VehicleDevice &&
+		r.StartTime.Before(o.EndTime) && o.StartTime.Before(r.EndTime)
+}
+
+// ResolveSwipe picks the reservation a swipe is meant for out of the candidates in the order they were received,
+// nil means the swipe is rejected as ambiguous
+func (sr SwipeResolution) ResolveSwipe(candidates []*Reservation) *Reservation {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	for _, value := range candidates {
+		if t := value.GetCurrentTrip(); t != nil && (t.Status == IN_PROGRESS || t.Status == LATE) {
+			return value
+		}
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+
+	switch sr {
+	case SWIPE_FIRST:
+		return candidates[0]
+	case SWIPE_EARLIEST_END:
+		earliest := candidates[0]
+		for _, value := range candidates[1:] {
+			if value.EndTime.Before(earliest.EndTime) {
+				earliest = value
+			}
+		}
+
+		return earliest
+	case SWIPE_REJECT:
+		return nil
+	default:
+		return candidates[len(candidates)-1]
+	}
+}
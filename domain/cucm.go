@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"time"
+)
+
+type CUCMRequestState string
+
+const (
+	CUCM_PENDING  CUCMRequestState = "Pending"
+	CUCM_ANSWERED CUCMRequestState = "Answered"
+	CUCM_EXPIRED  CUCMRequestState = "Expired"
+
+	DEFAULT_CUCM_TIMEOUT = 2 * time.Minute
+	CUCM_EXPIRED_HISTORY = 1000
+)
+
+// PendingCUCMRequest is a swipe without reservation waiting for the AnswerRequest of Tako
+type PendingCUCMRequest struct {
+	Guid       string
+	Swipe      *DriverSwipe
+	State      CUCMRequestState
+	SentTime   time.Time
+	ExpiryTime time.Time
+}
+
+type CUCMRequests struct {
+	Timeout string
+	Pending []*PendingCUCMRequest
+	Expired []*PendingCUCMRequest
+}
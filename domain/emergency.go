@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type TripKind string
+
+const (
+	ILLEGAL_TRIP    TripKind = "illegal"
+	EMERGENCY_TRIP  TripKind = "emergency"
+	UNRESERVED_TRIP TripKind = "unreserved"
+
+	NO_EMERGENCY = "NoEmergencyTrip"
+
+	ILLEGAL_TRIP_STARTED   EventName = "IllegalTripStarted"
+	EMERGENCY_TRIP_STARTED EventName = "EmergencyTripStarted"
+)
+
+// NewTripWithoutReservation creates a trip driven without booking: an illegal or hot-wired trip without
+// valid card, an emergency release with its reason or a trip simply driven without reservation
+func NewTripWithoutReservation(kind TripKind, vd VehicleDevice, a AccessDevice, emergencyReason string) (*Trip, error) {
+	t := new(Trip)
+	t.VehicleDevice = vd
+	t.AccessDevice = a.Normalise()
+	t.TripNo = 1
+	t.WithoutReservation = true
+
+	switch kind {
+	case ILLEGAL_TRIP:
+		//the data fob is never taken out, so it is not returned either
+		t.Illegal = true
+		t.KeepDataFob = true
+	case EMERGENCY_TRIP:
+		if emergencyReason == "" || emergencyReason == NO_EMERGENCY {
+			return nil, fmt.Errorf("Emergency trips require an emergency reason")
+		}
+
+		t.EmergencyTrip = true
+		t.EmergencyReason = emergencyReason
+	case UNRESERVED_TRIP:
+	default:
+		return nil, fmt.Errorf("Unsupported trip kind: %s", kind)
+	}
+
+	return t, nil
+}
+
+func (t *Trip) GetEmergencyReason() string {
+	if !t.EmergencyTrip {
+		return NO_EMERGENCY
+	}
+
+	return t.EmergencyReason
+}
+
+func (t *Trip) GenerateIllegalTrip(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateProblemEvent(e, v, now, ILLEGAL_TRIP_STARTED, t, nil)
+}
+
+func (t *Trip) GenerateEmergencyTrip(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateProblemEvent(e, v, now, EMERGENCY_TRIP_STARTED, t, nil)
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+)
+
+type tripFlags struct {
+	Illegal            bool
+	EmergencyTrip      bool
+	EmergencyReason    string
+	WithoutReservation bool
+}
+
+type segmentMessage struct {
+	Flags tripFlags `xml:"Body>RawSegmentEvaluated>segment"`
+}
+
+type problemEvent struct {
+	Description string
+	tripFlags
+}
+
+type problemEventMessage struct {
+	Problem problemEvent `xml:"Body>UsageProblemEventReceived>usageProblem"`
+}
+
+func TestFlagsOfTripsWithoutReservation(t *testing.T) {
+	e := DefaultEnvironment(1)
+	v := GetInterfaceVersion(DEFAULT_INTERFACE_VERSION)
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	vd := VehicleDevice{VehiclePhoneNo: "500", OrgaNo: "1"}
+
+	cases := []struct {
+		kind    TripKind
+		reason  string
+		flags   tripFlags
+		problem func(t *Trip) string
+		event   EventName
+	}{
+		{ILLEGAL_TRIP, "", tripFlags{Illegal: true, EmergencyReason: NO_EMERGENCY, WithoutReservation: true},
+			func(t *Trip) string { return t.GenerateIllegalTrip(e, v, now) }, ILLEGAL_TRIP_STARTED},
+		{EMERGENCY_TRIP, "EmergencyCard", tripFlags{EmergencyTrip: true, EmergencyReason: "EmergencyCard", WithoutReservation: true},
+			func(t *Trip) string { return t.GenerateEmergencyTrip(e, v, now) }, EMERGENCY_TRIP_STARTED},
+		{UNRESERVED_TRIP, "", tripFlags{EmergencyReason: NO_EMERGENCY, WithoutReservation: true},
+			func(t *Trip) string { return t.GenerateDriverLate(e, v, now) }, LATE_DRIVER},
+	}
+
+	for _, value := range cases {
+		trip, err := NewTripWithoutReservation(value.kind, vd, AccessDevice{SmartcardSerialNo: "1"}, value.reason)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trip.Vehicle = NewVehicle(e.Random, vd)
+
+		segment := new(segmentMessage)
+		if err := xml.Unmarshal([]byte(trip.GenerateTripSegment(e, v, now)), segment); err != nil {
+			t.Fatal(value.kind, err)
+		}
+		if segment.Flags != value.flags {
+			t.Errorf("%s segment flags %+v, want %+v", value.kind, segment.Flags, value.flags)
+		}
+
+		problem := new(problemEventMessage)
+		if err := xml.Unmarshal([]byte(value.problem(trip)), problem); err != nil {
+			t.Fatal(value.kind, err)
+		}
+		if problem.Problem.Description != string(value.event) || problem.Problem.tripFlags != value.flags {
+			t.Errorf("%s problem event %s with flags %+v, want %s with %+v", value.kind, problem.Problem.Description, problem.Problem.tripFlags, value.event, value.flags)
+		}
+	}
+}
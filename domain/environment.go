@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"time"
+)
+
+// Environment is what the devices of one simulator share: the settings, the timezone table and the random
+// source. Every simulator has its own, so that several of them run side by side in one process.
+type Environment struct {
+	Settings  Settings
+	Timezones *Timezones
+	Random    *Random
+}
+
+func NewEnvironment(s Settings, seed int64) (*Environment, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	tz, err := NewTimezones(s.Timezones)
+	if err != nil {
+		return nil, err
+	}
+
+	e := new(Environment)
+	e.Settings = s
+	e.Timezones = tz
+	e.Random = NewRandom(seed)
+
+	return e, nil
+}
+
+// DefaultEnvironment uses the default settings
+func DefaultEnvironment(seed int64) *Environment {
+	e, err := NewEnvironment(DefaultSettings(), seed)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+// GetDefaultLocation is the timezone of the messages without reservation
+func (e *Environment) GetDefaultLocation() *time.Location {
+	return e.Timezones.GetLocation(e.Settings.DefaultTimezone)
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// LateOptions configures the DelayedTripEnd reminders repeated after the first late alarm of a trip
+type LateOptions struct {
+	ReminderInterval time.Duration `yaml:"reminderInterval"`
+	MaxReminders     int           `yaml:"maxReminders"`
+}
+
+func (o *LateOptions) Validate() error {
+	if o.ReminderInterval < 0 || o.MaxReminders < 0 {
+		return fmt.Errorf("Late reminder interval and count must not be negative")
+	} else if o.MaxReminders > 0 && o.ReminderInterval == 0 {
+		return fmt.Errorf("Late reminders require an interval")
+	}
+
+	return nil
+}
+
+// RecordDelay keeps the delay of a trip returned after its reservation end
+func (t *Trip) RecordDelay() {
+	if t.Reservation == nil || !t.EndTime.After(t.Reservation.EndTime) {
+		return
+	}
+
+	t.Late = t.Status == LATE
+	t.DelayMinutes = int((t.EndTime.Sub(t.Reservation.EndTime) + time.Minute - 1) / time.Minute)
+}
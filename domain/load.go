@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type LoadScenario string
+
+const (
+	REGULAR_TRIP LoadScenario = "regular"
+	LATE_RETURN  LoadScenario = "late"
+	NO_SHOW      LoadScenario = "noShow"
+)
+
+type LoadOptions struct {
+	OrgaNo       string        `yaml:"orga"`
+	Vehicles     int           `yaml:"vehicles"`
+	Rate         float64       `yaml:"rate"` //reservations per minute
+	TripDuration time.Duration `yaml:"tripDuration"`
+	LateRatio    float64       `yaml:"lateRatio"`
+	NoShowRatio  float64       `yaml:"noShowRatio"`
+}
+
+func (o *LoadOptions) Validate() error {
+	if o.Vehicles <= 0 || o.Rate <= 0 {
+		return fmt.Errorf("Load mode needs at least one vehicle and a positive rate")
+	} else if o.TripDuration < 5*time.Minute {
+		return fmt.Errorf("Load trips must last at least 5 minutes")
+	} else if o.LateRatio < 0 || o.NoShowRatio < 0 || o.LateRatio+o.NoShowRatio > 1 {
+		return fmt.Errorf("Late and no-show ratios must not be negative and add up to at most 1: %v, %v", o.LateRatio, o.NoShowRatio)
+	}
+
+	return nil
+}
+
+// NextScenario picks the scenario of the next reservation according to the configured ratios
+func (o *LoadOptions) NextScenario(random *Random) LoadScenario {
+	p := float64(random.Int(1000)) / 1000
+
+	if p < o.NoShowRatio {
+		return NO_SHOW
+	} else if p < o.NoShowRatio+o.LateRatio {
+		return LATE_RETURN
+	}
+
+	return REGULAR_TRIP
+}
+
+type MessageStats struct {
+	Count         int
+	Errors        int
+	ErrorRate     float64
+	AvgResponseMs float64
+	MaxResponseMs float64
+
+	total time.Duration
+}
+
+func (ms *MessageStats) Add(failed bool, d time.Duration) {
+	ms.Count++
+	if failed {
+		ms.Errors++
+	}
+	ms.total += d
+
+	ms.ErrorRate = float64(ms.Errors) / float64(ms.Count)
+	ms.AvgResponseMs = float64(ms.total) / float64(ms.Count) / float64(time.Millisecond)
+
+	if v := float64(d) / float64(time.Millisecond); v > ms.MaxResponseMs {
+		ms.MaxResponseMs = v
+	}
+}
+
+type LoadStats struct {
+	Started       time.Time
+	Options       LoadOptions
+	Reservations  int
+	Trips         int
+	LateReturns   int
+	NoShows       int
+	Skipped       int
+	ScheduledJobs int
+	Total         *MessageStats
+	Messages      map[string]*MessageStats
+}
+
+func NewLoadStats(o LoadOptions, now time.Time) *LoadStats {
+	s := new(LoadStats)
+
+	s.Started = now
+	s.Options = o
+	s.Total = new(MessageStats)
+	s.Messages = make(map[string]*MessageStats)
+
+	return s
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Persona describes how a driver uses a reservation, all times are in minutes
+type Persona struct {
+	Name             string
+	NoShow           bool
+	StartDelay       int //after the reservation start
+	ReturnOffset     int //relative to the reservation end, negative for early returns
+	Jitter           int //random spread applied to start and return
+	Stops            int //short ignition off/on cycles during the trip
+	KeepDataFob      bool
+	DisableLateAlarm bool
+}
+
+type PersonaAssignments struct {
+	Default      string
+	Reservations map[string]string
+	Cards        []CardAssignment
+}
+
+// PersonaState is the part of the saved state that lets the personas drive their reservations after a restart
+type PersonaState struct {
+	Personas    map[string]*Persona
+	Assignments PersonaAssignments
+	Played      map[string]string //persona that drives a reservation, empty when none was assigned
+}
+
+// CardAssignment gives a persona to the reservations of a card, the card is matched like the swipes
+type CardAssignment struct {
+	Card    AccessDevice
+	Persona string
+}
+
+var personas = map[string]*Persona{
+	"punctual":     &Persona{Name: "punctual", StartDelay: 2, ReturnOffset: -5, Jitter: 1},
+	"noShow":       &Persona{Name: "noShow", NoShow: true},
+	"late":         &Persona{Name: "late", StartDelay: 2, ReturnOffset: 30, Jitter: 2},
+	"lateNoAlarm":  &Persona{Name: "lateNoAlarm", StartDelay: 2, ReturnOffset: 30, Jitter: 2, DisableLateAlarm: true},
+	"earlyReturn":  &Persona{Name: "earlyReturn", StartDelay: 2, ReturnOffset: -30, Jitter: 5},
+	"shortStops":   &Persona{Name: "shortStops", StartDelay: 2, ReturnOffset: -5, Jitter: 1, Stops: 6},
+	"keepsDataFob": &Persona{Name: "keepsDataFob", StartDelay: 2, ReturnOffset: -5, Jitter: 1, KeepDataFob: true},
+}
+
+func GetPersonas() map[string]*Persona {
+	return personas
+}
+
+func (p *Persona) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("Persona name is required")
+	} else if p.StartDelay < 0 || p.Jitter < 0 || p.Stops < 0 {
+		return fmt.Errorf("StartDelay, Jitter and Stops of persona %s must not be negative", p.Name)
+	}
+
+	return nil
+}
+
+func (p *Persona) jitter(random *Random) time.Duration {
+	if p.Jitter == 0 {
+		return 0
+	}
+
+	return time.Duration(random.Int(2*p.Jitter*60+1)-p.Jitter*60) * time.Second
+}
+
+// GetSwipeTimes draws the start and return swipes of the persona for a reservation
+func (p *Persona) GetSwipeTimes(random *Random, r *Reservation, now time.Time) (time.Time, time.Time) {
+	random = random.Derive("persona", r.ReservationId, r.StartTime.Unix())
+
+	start := r.StartTime.Add(time.Duration(p.StartDelay)*time.Minute + p.jitter(random))
+	end := r.EndTime.Add(time.Duration(p.ReturnOffset)*time.Minute + p.jitter(random))
+
+	//a swipe is only accepted strictly after the reservation start
+	if !start.After(r.StartTime) {
+		start = r.StartTime.Add(time.Second)
+	}
+	if start.Before(now) {
+		start = now.Add(time.Second)
+	}
+	if end.Before(start.Add(time.Minute)) {
+		end = start.Add(time.Minute)
+	}
+
+	return start, end
+}
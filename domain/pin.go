@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"encoding/json"
+)
+
+type PINResult string
+
+// Secret is a PIN, its JSON is masked so that the API does not show it
+type Secret string
+
+const (
+	PIN_OK       PINResult = "OK"
+	PIN_WRONG    PINResult = "WrongPIN"
+	PIN_BLOCKED  PINResult = "Blocked"
+	PIN_REQUIRED PINResult = "PINRequired"
+
+	DEFAULT_PIN_TRIES = 3
+)
+
+// CheckPIN plays the PIN attempts of a swipe against the expected PIN, failed counts the wrong
+// attempts of the card since its last correct PIN and blocks the card once it reaches maxTries.
+// A swipe without attempts is refused when a PIN is expected, a blocked card is refused right away.
+// It returns the result, the number of attempts played and the new failure count.
+func CheckPIN(expected string, attempts []string, failed int, maxTries int) (PINResult, int, int) {
+	if expected == "" {
+		return PIN_OK, 1, failed
+	}
+
+	if maxTries <= 0 {
+		maxTries = DEFAULT_PIN_TRIES
+	}
+
+	if failed >= maxTries {
+		return PIN_BLOCKED, 0, failed
+	}
+
+	if len(attempts) == 0 {
+		return PIN_REQUIRED, 0, failed
+	}
+
+	tries := 0
+	for _, value := range attempts {
+		tries++
+
+		if value == expected {
+			return PIN_OK, tries, 0
+		}
+
+		failed++
+		if failed >= maxTries {
+			return PIN_BLOCKED, tries, failed
+		}
+	}
+
+	return PIN_WRONG, tries, failed
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(maskPIN(string(s)))
+}
+
+// BcStatus is the status of the board computer after the PIN check, a driver who still has to type
+// the right PIN keeps it waiting
+func (r PINResult) BcStatus() string {
+	switch r {
+	case PIN_OK:
+		return "PINAccepted"
+	case PIN_BLOCKED:
+		return "CardBlocked"
+	}
+
+	return "WaitingForPIN"
+}
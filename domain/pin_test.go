@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestCheckPIN(t *testing.T) {
+	tests := []struct {
+		name       string
+		expected   string
+		attempts   []string
+		failed     int
+		maxTries   int
+		wantResult PINResult
+		wantTries  int
+		wantFailed int
+	}{
+		{"no PIN expected", "", nil, 0, 3, PIN_OK, 1, 0},
+		{"no PIN expected ignores attempts", "", []string{"1"}, 0, 3, PIN_OK, 1, 0},
+		{"PIN expected without attempts", "1234", nil, 0, 3, PIN_REQUIRED, 0, 0},
+		{"PIN expected without attempts keeps failures", "1234", nil, 1, 3, PIN_REQUIRED, 0, 1},
+		{"right PIN", "1234", []string{"1234"}, 0, 3, PIN_OK, 1, 0},
+		{"right PIN after a wrong one", "1234", []string{"0000", "1234"}, 0, 3, PIN_OK, 2, 0},
+		{"wrong PIN", "1234", []string{"0000"}, 0, 3, PIN_WRONG, 1, 1},
+		{"wrong PINs block the card", "1234", []string{"0", "1", "2", "1234"}, 0, 3, PIN_BLOCKED, 3, 3},
+		{"earlier failures count", "1234", []string{"0"}, 2, 3, PIN_BLOCKED, 1, 3},
+		{"blocked card refused up front", "1234", []string{"1234"}, 3, 3, PIN_BLOCKED, 0, 3},
+		{"default tries", "1234", []string{"0", "1", "2"}, 0, 0, PIN_BLOCKED, 3, 3},
+	}
+
+	for _, tt := range tests {
+		result, tries, failed := CheckPIN(tt.expected, tt.attempts, tt.failed, tt.maxTries)
+
+		if result != tt.wantResult || tries != tt.wantTries || failed != tt.wantFailed {
+			t.Errorf("%s: CheckPIN = %s, %d, %d, want %s, %d, %d", tt.name, result, tries, failed, tt.wantResult, tt.wantTries, tt.wantFailed)
+		}
+	}
+}
+
+func TestPINResultBcStatus(t *testing.T) {
+	tests := map[PINResult]string{
+		PIN_OK:       "PINAccepted",
+		PIN_WRONG:    "WaitingForPIN",
+		PIN_REQUIRED: "WaitingForPIN",
+		PIN_BLOCKED:  "CardBlocked",
+	}
+
+	for result, want := range tests {
+		if got := result.BcStatus(); got != want {
+			t.Errorf("%s.BcStatus() = %q, want %q", result, got, want)
+		}
+	}
+}
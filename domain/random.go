@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// Random is the seeded source of all random values of a simulator. The values are drawn from sources
+// derived from the seed and a stable key, so they do not depend on the order in which concurrent requests
+// and jobs draw them:
+//   - the source number and odometer of a vehicle, keyed by vehicle
+//   - the swipe times of a persona, keyed by reservation and start
+//   - the scenario of a load reservation, keyed by reservation
+//   - the guid of a CUCM request, keyed by vehicle, task number and swipe time
+//
+// With the same seed, settings and inputs a run sends the same values. Requests received at the same time
+// may be numbered in another order, which changes their task numbers and CUCM guids, and neither the order
+// of messages sent at the same time nor anything timed by the system clock is reproducible.
+type Random struct {
+	mutex  sync.Mutex
+	seed   int64
+	random *rand.Rand
+}
+
+func NewRandom(seed int64) *Random {
+	r := new(Random)
+	r.seed = seed
+	r.random = rand.New(rand.NewSource(seed))
+
+	return r
+}
+
+func (r *Random) GetSeed() int64 {
+	return r.seed
+}
+
+// Derive returns the source of a key, the same seed and key always give the same values
+func (r *Random) Derive(keys ...interface{}) *Random {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%v", r.seed, keys)
+
+	return NewRandom(int64(h.Sum64()))
+}
+
+func (r *Random) Int(n int) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.random.Intn(n)
+}
+
+func (r *Random) Int63() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.random.Int63()
+}
+
+func (r *Random) NewGuid() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	u, err := uuid.NewRandomFromReader(r.random)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return u.String()
+}
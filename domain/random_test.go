@@ -0,0 +1,34 @@
+package domain
+
+import "testing"
+
+func TestVehiclesDoNotDependOnTheOrder(t *testing.T) {
+	a, b := VehicleDevice{OrgaNo: "1", VehiclePhoneNo: "500"}, VehicleDevice{OrgaNo: "1", VehiclePhoneNo: "501"}
+
+	first := NewRandom(7)
+	first.NewGuid()
+	a1, b1 := NewVehicle(first, a), NewVehicle(first, b)
+
+	second := NewRandom(7)
+	b2, a2 := NewVehicle(second, b), NewVehicle(second, a)
+
+	if a1.SourceNo != a2.SourceNo || a1.Odometer != a2.Odometer || b1.SourceNo != b2.SourceNo || b1.Odometer != b2.Odometer {
+		t.Errorf("vehicles differ with the creation order: %v %v, %v %v", a1, a2, b1, b2)
+	}
+
+	if other := NewVehicle(NewRandom(8), a); other.SourceNo == a1.SourceNo {
+		t.Errorf("seeds 7 and 8 give the same source number %s", a1.SourceNo)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	r := NewRandom(7)
+
+	if r.Derive("cucm", "500", 1).NewGuid() != NewRandom(7).Derive("cucm", "500", 1).NewGuid() {
+		t.Error("the same seed and key give different guids")
+	}
+
+	if r.Derive("cucm", "500", 1).NewGuid() == r.Derive("cucm", "500", 2).NewGuid() {
+		t.Error("different keys give the same guid")
+	}
+}
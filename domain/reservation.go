@@ -2,13 +2,13 @@ package domain
 
 import (
 	"fmt"
-	"math/rand"
 	"time"
 )
 
 type Reservation struct {
 	Timezone      int `xml:"Body>SendReservation>task>Reservation>Start>Timezone"`
 	TechStatus    TaskStatus
+	TaskError     TaskError
 	VehicleDevice VehicleDevice `xml:"Body>SendReservation>task>Destination"`
 	AccessDevice  AccessDevice  `xml:"Body>SendReservation>task>Reservation>UserAccessList>UserAccess"`
 	ReservationId string        `xml:"Body>SendReservation>task>Reservation>ReservationNo"`
@@ -17,7 +17,17 @@ type Reservation struct {
 	EndTime       time.Time     `xml:"Body>SendReservation>task>Reservation>Stop>UTCDateTime"`
 	LateAlarm     bool          `xml:"Body>SendReservation>task>Reservation>ReturnOptions>DelayMessage"`
 	LateBuffer    int           `xml:"Body>SendReservation>task>Reservation>ReturnOptions>DelayTime"`
-	Trip          *Trip
+	Trips         []*Trip
+	Revision      int
+	History       []ReservationChange
+}
+
+type ReservationChange struct {
+	Time     time.Time
+	Revision int
+	Field    string
+	Old      string
+	New      string
 }
 
 type AccessDevice struct {
@@ -25,39 +35,119 @@ type AccessDevice struct {
 	SmartcardCardNo   string `xml:"CardNo"`
 	SmartcardOrgaNo   string `xml:"CardOrga"`
 	SmartcardType     string `xml:"Type"`
+	PIN               Secret `xml:"PIN"`
+}
+
+func (a AccessDevice) GetVirtualAccessDevice() VirtualAccessDevice {
+	return VirtualAccessDevice{
+		SmartcardSerialNo: a.SmartcardSerialNo,
+		SmartcardCardNo:   a.SmartcardCardNo,
+		SmartcardOrgaNo:   a.SmartcardOrgaNo,
+		SmartcardType:     a.SmartcardType,
+	}
 }
 
-func (r *Reservation) GetTimezone() *time.Location {
-	var loc *time.Location
-
-	switch r.Timezone {
-	case 2:
-		loc, _ = time.LoadLocation("Pacific/Honolulu")
-		break
-	case 4:
-		loc, _ = time.LoadLocation("PST8PDT")
-		break
-	case 10:
-		loc, _ = time.LoadLocation("MST7MDT")
-		break
-	case 20:
-		loc, _ = time.LoadLocation("CST6CDT")
-		break
-	case 35:
-		loc, _ = time.LoadLocation("EST5EDT")
-		break
-	case 85:
-		loc, _ = time.LoadLocation("Europe/London")
-		break
+func (r *Reservation) GetTimezone(e *Environment) *time.Location {
+	return e.Timezones.GetLocation(r.Timezone)
+}
+
+func (r *Reservation) GetTimezoneCode(e *Environment) int {
+	return r.Timezone
+}
+
+// Update applies the booking of n to the reservation and records each changed field in its history,
+// the trips of the reservation are kept
+func (r *Reservation) Update(n *Reservation, now time.Time) []ReservationChange {
+	changes := make([]ReservationChange, 0)
+
+	change := func(field string, old interface{}, new interface{}) {
+		if fmt.Sprint(old) != fmt.Sprint(new) {
+			changes = append(changes, ReservationChange{Time: now, Revision: r.Revision + 1, Field: field, Old: fmt.Sprint(old), New: fmt.Sprint(new)})
+		}
 	}
 
-	return loc
+	change("StartTime", r.StartTime.UTC(), n.StartTime.UTC())
+	change("EndTime", r.EndTime.UTC(), n.EndTime.UTC())
+	change("Timezone", r.Timezone, n.Timezone)
+	change("VehicleDevice", r.VehicleDevice, n.VehicleDevice)
+	change("AccessDevice", r.AccessDevice.GetVirtualAccessDevice(), n.AccessDevice.GetVirtualAccessDevice())
+
+	//the history records that the PIN changed, never the PIN itself
+	if r.AccessDevice.PIN != n.AccessDevice.PIN {
+		changes = append(changes, ReservationChange{Time: now, Revision: r.Revision + 1, Field: "PIN", Old: maskPIN(string(r.AccessDevice.PIN)), New: maskPIN(string(n.AccessDevice.PIN))})
+	}
+	change("LateAlarm", r.LateAlarm, n.LateAlarm)
+	change("LateBuffer", r.LateBuffer, n.LateBuffer)
+
+	r.TechStatus = n.TechStatus
+	r.RequestId = n.RequestId
+
+	if len(changes) == 0 {
+		return changes
+	}
+
+	r.Timezone = n.Timezone
+	r.VehicleDevice = n.VehicleDevice
+	r.AccessDevice = n.AccessDevice
+	r.StartTime = n.StartTime
+	r.EndTime = n.EndTime
+	r.LateAlarm = n.LateAlarm
+	r.LateBuffer = n.LateBuffer
+	r.Revision++
+	r.History = append(r.History, changes...)
+
+	return changes
+}
+
+func maskPIN(pin string) string {
+	if pin == "" {
+		return ""
+	}
+
+	return "****"
+}
+
+func (r *Reservation) GetLateTime() time.Time {
+	return r.EndTime.Add(time.Minute * time.Duration(r.LateBuffer))
+}
+
+func (r *Reservation) GetCurrentTrip() *Trip {
+	if len(r.Trips) == 0 {
+		return nil
+	}
+
+	return r.Trips[len(r.Trips)-1]
+}
+
+func (r *Reservation) NewTrip() *Trip {
+	t := new(Trip)
+	t.VehicleDevice = r.VehicleDevice
+	t.AccessDevice = r.AccessDevice
+	t.ReservationId = r.ReservationId
+	t.Reservation = r
+	t.TripNo = len(r.Trips) + 1
+
+	r.Trips = append(r.Trips, t)
+
+	return t
 }
 
 func (r *Reservation) GetTechStatus() TaskStatus {
 	return r.TechStatus
 }
 
+func (r *Reservation) GetTaskError() TaskError {
+	if r.TaskError == "" {
+		return NO_ERROR
+	}
+
+	return r.TaskError
+}
+
+func (r *Reservation) SetTaskError(te TaskError) {
+	r.TaskError = te
+}
+
 func (r *Reservation) GetRequestId() string {
 	return r.RequestId
 }
@@ -66,12 +156,16 @@ func (r *Reservation) GetOrgaNo() string {
 	return r.VehicleDevice.OrgaNo
 }
 
-func (r *Reservation) GenerateStatus() string {
-	return generateStatus(RequestI(r))
+func (r *Reservation) GetVehicleDevice() VehicleDevice {
+	return r.VehicleDevice
+}
+
+func (r *Reservation) GenerateStatus(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateStatus(e, v, now, RequestI(r))
 }
 
-func (r *Reservation) GenerateTaskNumber() {
-	r.RequestId = fmt.Sprint(rand.Intn(1000000))
+func (r *Reservation) SetRequestId(id string) {
+	r.RequestId = id
 }
 
 func (rt *Reservation) String() string {
@@ -89,19 +183,19 @@ func (rt *Reservation) String() string {
 	return string
 }
 
-func (r *Reservation) GenerateResponse() string {
+func (r *Reservation) GenerateResponse(e *Environment, now time.Time) string {
 	return "<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"\n\t<s:Body>" +
 		"\n\t\t<SendReservationResponse xmlns=\"http://tempuri.org/\">" +
 		"\n\t\t\t<SendReservationResult xmlns:a=\"http://invers.com\" xmlns:i=\"http://www.w3.org/2001/XMLSchema-instance\">" +
-		"\n\t\t\t\t<a:CustomerId>00000000-0000-0000-0000-000000000000</a:CustomerId>" +
+		"\n\t\t\t\t<a:CustomerId>" + e.Settings.Identifiers.CustomerId + "</a:CustomerId>" +
 		"\n\t\t\t\t<a:DataStatus>Sending</a:DataStatus>" +
-		"\n\t\t\t\t<a:TaskError>NoError</a:TaskError>" +
+		"\n\t\t\t\t<a:TaskError>" + fmt.Sprint(r.GetTaskError()) + "</a:TaskError>" +
 		"\n\t\t\t\t<a:TaskNumber>" + r.GetRequestId() + "</a:TaskNumber>" +
 		"\n\t\t\t\t<a:TaskSendStatus>" + fmt.Sprint(r.GetTechStatus()) + "</a:TaskSendStatus>" +
 		"\n\t\t\t\t<a:Timestamp xmlns:b=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\">" +
-		"\n\t\t\t\t\t<b:Timezone>20</b:Timezone>" +
-		"\n\t\t\t\t\t<b:UTCDateTime>" + time.Now().UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
+		"\n\t\t\t\t\t<b:Timezone>" + fmt.Sprint(r.GetTimezoneCode(e)) + "</b:Timezone>" +
+		"\n\t\t\t\t\t<b:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
 		"\n\t\t\t\t</a:Timestamp>" +
 		"\n\t\t\t\t<a:UsedCommsystem>Unknown</a:UsedCommsystem>" +
 		"\n\t\t\t</SendReservationResult>" +
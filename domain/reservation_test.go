@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateKeepsThePINOutOfTheHistory(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	r := &Reservation{ReservationId: "R1", AccessDevice: AccessDevice{SmartcardSerialNo: "1", SmartcardType: MIFARE, PIN: "1234"}}
+	n := &Reservation{ReservationId: "R1", AccessDevice: AccessDevice{SmartcardSerialNo: "2", SmartcardType: MIFARE, PIN: "5678"}}
+
+	changes := r.Update(n, now)
+
+	if len(changes) != 2 || changes[0].Field != "AccessDevice" || changes[1].Field != "PIN" {
+		t.Fatalf("changes %v, want the card and the PIN", changes)
+	}
+
+	for _, value := range changes {
+		if strings.Contains(value.Old+value.New, "1234") || strings.Contains(value.Old+value.New, "5678") {
+			t.Errorf("PIN recorded in the history: %v", value)
+		}
+	}
+
+	if r.AccessDevice.PIN != "5678" {
+		t.Errorf("PIN %s, want the updated one", r.AccessDevice.PIN)
+	}
+}
@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Timings are the delays of the simulated device, each one counted from the previous message
+type Timings struct {
+	StatusUpdate    time.Duration `yaml:"statusUpdate"`
+	TripStart       time.Duration `yaml:"tripStart"`
+	Unlock          time.Duration `yaml:"unlock"`
+	DoorOpen        time.Duration `yaml:"doorOpen"`
+	DoorClose       time.Duration `yaml:"doorClose"`
+	DataFobRemoved  time.Duration `yaml:"dataFobRemoved"`
+	FirstIgnition   time.Duration `yaml:"firstIgnition"`
+	TripEnd         time.Duration `yaml:"tripEnd"`
+	DataFobReturned time.Duration `yaml:"dataFobReturned"`
+	ReturnDoorOpen  time.Duration `yaml:"returnDoorOpen"`
+	ReturnDoorClose time.Duration `yaml:"returnDoorClose"`
+	ReturnLock      time.Duration `yaml:"returnLock"`
+	TripData        time.Duration `yaml:"tripData"`
+	TripSegment     time.Duration `yaml:"tripSegment"`
+	SegmentInterval time.Duration `yaml:"segmentInterval"`
+	TripComplete    time.Duration `yaml:"tripComplete"`
+	DriverLate      time.Duration `yaml:"driverLate"`
+	ProblemEvent    time.Duration `yaml:"problemEvent"`
+	RejectedAccess  time.Duration `yaml:"rejectedAccess"`
+	CUCMRequest     time.Duration `yaml:"cucmRequest"`
+	CommandEvent    time.Duration `yaml:"commandEvent"`
+}
+
+// Odometer is what the vehicle consumes per driven trip segment
+type Odometer struct {
+	SegmentDistance int `yaml:"segmentDistance"`
+	SegmentFuel     int `yaml:"segmentFuel"`
+}
+
+// Identifiers are the fixed values sent in the generated Invers messages
+type Identifiers struct {
+	CustomerId    string `yaml:"customerId"`
+	SegmentId     string `yaml:"segmentId"`
+	EventId       string `yaml:"eventId"`
+	CUCMRequestId string `yaml:"cucmRequestId"`
+	CardExtension int    `yaml:"cardExtension"` //extension byte of the smartcards read by the devices
+}
+
+// Settings gather the behaviour of the simulated devices
+type Settings struct {
+	Timings         Timings        `yaml:"timings"`
+	Odometer        Odometer       `yaml:"odometer"`
+	Identifiers     Identifiers    `yaml:"identifiers"`
+	Timezones       map[int]string `yaml:"timezones"`       //Invers codes added to or replacing the default mapping
+	DefaultTimezone int            `yaml:"defaultTimezone"` //code of the messages without reservation
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		Timings: Timings{
+			StatusUpdate:    5 * time.Second,
+			TripStart:       30 * time.Second,
+			Unlock:          5 * time.Second,
+			DoorOpen:        5 * time.Second,
+			DoorClose:       5 * time.Second,
+			DataFobRemoved:  10 * time.Second,
+			FirstIgnition:   10 * time.Second,
+			TripEnd:         30 * time.Second,
+			DataFobReturned: 10 * time.Second,
+			ReturnDoorOpen:  5 * time.Second,
+			ReturnDoorClose: 5 * time.Second,
+			ReturnLock:      5 * time.Second,
+			TripData:        5 * time.Second,
+			TripSegment:     5 * time.Second,
+			SegmentInterval: 5 * time.Minute,
+			TripComplete:    60 * time.Second,
+			DriverLate:      5 * time.Second,
+			ProblemEvent:    35 * time.Second,
+			RejectedAccess:  30 * time.Second,
+			CUCMRequest:     30 * time.Second,
+			CommandEvent:    5 * time.Second,
+		},
+		Odometer: Odometer{
+			SegmentDistance: 3,
+			SegmentFuel:     1,
+		},
+		Identifiers: Identifiers{
+			CustomerId:    "00000000-0000-0000-0000-000000000000",
+			SegmentId:     "654",
+			EventId:       "27642813",
+			CUCMRequestId: "40931",
+			CardExtension: 32,
+		},
+		Timezones:       make(map[int]string),
+		DefaultTimezone: DEFAULT_TIMEZONE,
+	}
+}
+
+func (s *Settings) Validate() error {
+	timings := reflect.ValueOf(s.Timings)
+	for i := 0; i < timings.NumField(); i++ {
+		if timings.Field(i).Interface().(time.Duration) < 0 {
+			return fmt.Errorf("Timing %s must not be negative", timings.Type().Field(i).Name)
+		}
+	}
+
+	if s.Timings.SegmentInterval <= 0 {
+		return fmt.Errorf("Segment interval must be positive")
+	}
+
+	if s.Odometer.SegmentDistance < 0 || s.Odometer.SegmentFuel < 0 {
+		return fmt.Errorf("Segment distance and fuel must not be negative")
+	}
+
+	if s.Identifiers.CustomerId == "" || s.Identifiers.SegmentId == "" || s.Identifiers.EventId == "" || s.Identifiers.CUCMRequestId == "" {
+		return fmt.Errorf("Customer, segment, event and CUCM request identifiers are required")
+	}
+
+	if s.Identifiers.CardExtension < 0 || s.Identifiers.CardExtension > 255 {
+		return fmt.Errorf("Card extension must be between 0 and 255: %d", s.Identifiers.CardExtension)
+	}
+
+	for code, name := range s.Timezones {
+		if _, err := time.LoadLocation(name); err != nil {
+			return fmt.Errorf("Invalid timezone %q for code %d: %v", name, code, err)
+		}
+	}
+
+	if _, ok := s.Timezones[s.DefaultTimezone]; !ok && timezones[s.DefaultTimezone] == "" {
+		return fmt.Errorf("Unknown default timezone code: %d", s.DefaultTimezone)
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "testing"
+
+func TestValidateIdentifiers(t *testing.T) {
+	cases := []struct {
+		name  string
+		edit  func(s *Settings)
+		valid bool
+	}{
+		{"defaults", func(s *Settings) {}, true},
+		{"no CUCM request id", func(s *Settings) { s.Identifiers.CUCMRequestId = "" }, false},
+		{"card extension 0", func(s *Settings) { s.Identifiers.CardExtension = 0 }, true},
+		{"negative card extension", func(s *Settings) { s.Identifiers.CardExtension = -1 }, false},
+		{"card extension over a byte", func(s *Settings) { s.Identifiers.CardExtension = 256 }, false},
+	}
+
+	for _, value := range cases {
+		s := DefaultSettings()
+		value.edit(&s)
+
+		if err := s.Validate(); (err == nil) != value.valid {
+			t.Errorf("%s: Validate() = %v", value.name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+type CUCMAnswer string
+
+const (
+	CUCM_ANSWER_NONE   CUCMAnswer = ""
+	CUCM_ANSWER_ACCEPT CUCMAnswer = "accept"
+	CUCM_ANSWER_REJECT CUCMAnswer = "reject"
+
+	DEFAULT_SINK_MESSAGES = 10000
+)
+
+func (a CUCMAnswer) IsValid() bool {
+	return a == CUCM_ANSWER_NONE || a == CUCM_ANSWER_ACCEPT || a == CUCM_ANSWER_REJECT
+}
+
+type ReceivedMessage struct {
+	Id               int
+	InterfaceVersion string
+	OrgaNo           string
+	Path             string
+	Body             string
+	Time             time.Time
+}
+
+type MessageFilter struct {
+	OrgaNo   string
+	Path     string
+	Contains string
+}
+
+func (f *MessageFilter) Matches(m *ReceivedMessage) bool {
+	return (f.OrgaNo == "" || f.OrgaNo == m.OrgaNo) &&
+		(f.Path == "" || f.Path == m.Path) &&
+		(f.Contains == "" || strings.Contains(m.Body, f.Contains))
+}
+
+type SinkResponse struct {
+	Status int
+	Body   string
+}
+
+func NewSinkResponse() *SinkResponse {
+	sr := new(SinkResponse)
+
+	sr.Status = 200
+	sr.Body = "<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body/></s:Envelope>"
+
+	return sr
+}
+
+type CUCMRequest struct {
+	Guid           string       `xml:"Body>RequestReceived>request>Request>RequestID"`
+	VehiclePhoneNo string       `xml:"Body>RequestReceived>request>LoginName"`
+	OrgaNo         string       `xml:"Body>RequestReceived>request>Source>OrgaNo"`
+	AccessDevice   AccessDevice `xml:"Body>RequestReceived>request>Request>Access>UserAccess"`
+}
+
+// NewCUCMResponse builds the answer Tako would send back, an accepted request carries a one hour reservation
+func (cr *CUCMRequest) NewCUCMResponse(e *Environment, answer CUCMAnswer, now time.Time) *CUCMResponse {
+	r := new(CUCMResponse)
+
+	r.Guid = cr.Guid
+	r.Timezone = e.Settings.DefaultTimezone
+	r.VehicleDevice = VehicleDevice{VehiclePhoneNo: cr.VehiclePhoneNo, OrgaNo: cr.OrgaNo}
+	r.AccessDevice = cr.AccessDevice
+
+	if answer == CUCM_ANSWER_ACCEPT {
+		r.ReservationId = "CUCM-" + cr.Guid
+		//started slightly in the past so that the pending swipe falls into the reservation
+		r.StartTime = now.Add(-time.Minute)
+		r.EndTime = now.Add(time.Hour)
+	}
+
+	return r
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	HITAG_16     = "Hitag_16"
+	HITAG_32     = "Hitag_32"
+	MIFARE       = "Mifare"
+	LEGIC        = "Legic"
+	VIRTUAL_CARD = "Virtual"
+)
+
+// CardType tells how the smartcards of one type are named by Invers and identified,
+// Hitag cards carry no usable serial number and are identified by card number and card orga
+type CardType struct {
+	Name               string
+	Aliases            []string
+	IdentifiedByCardNo bool
+}
+
+var cardTypes = []*CardType{
+	&CardType{Name: HITAG_16, Aliases: []string{"Hitag16"}, IdentifiedByCardNo: true},
+	&CardType{Name: HITAG_32, Aliases: []string{"Hitag32"}, IdentifiedByCardNo: true},
+	&CardType{Name: MIFARE},
+	&CardType{Name: LEGIC},
+	&CardType{Name: VIRTUAL_CARD, Aliases: []string{"VirtualCard", "VirtualSmartCard"}},
+}
+
+func GetCardTypes() []*CardType {
+	return cardTypes
+}
+
+// GetCardType returns the type named t in any spelling, or nil for an unsupported type
+func GetCardType(t string) *CardType {
+	key := cardTypeKey(t)
+
+	for _, ct := range cardTypes {
+		if cardTypeKey(ct.Name) == key {
+			return ct
+		}
+
+		for _, alias := range ct.Aliases {
+			if cardTypeKey(alias) == key {
+				return ct
+			}
+		}
+	}
+
+	return nil
+}
+
+// NormaliseCardType returns the Invers name of the card type t, unsupported types are kept as they are
+func NormaliseCardType(t string) string {
+	if ct := GetCardType(t); ct != nil {
+		return ct.Name
+	}
+
+	return t
+}
+
+// Normalise returns the access device with its card type in the Invers spelling
+func (a AccessDevice) Normalise() AccessDevice {
+	a.SmartcardType = NormaliseCardType(a.SmartcardType)
+
+	return a
+}
+
+// Matches tells whether a and b identify the same card, cards of unsupported types are identified by serial number
+func (a AccessDevice) Matches(b AccessDevice) bool {
+	a, b = a.Normalise(), b.Normalise()
+
+	if a.SmartcardType != b.SmartcardType {
+		return false
+	}
+
+	if ct := GetCardType(a.SmartcardType); ct != nil && ct.IdentifiedByCardNo {
+		return a.SmartcardCardNo == b.SmartcardCardNo && a.SmartcardOrgaNo == b.SmartcardOrgaNo
+	}
+
+	return a.SmartcardSerialNo == b.SmartcardSerialNo
+}
+
+// card types are compared ignoring case, blanks, dashes and underscores
+func cardTypeKey(t string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+
+		return -1
+	}, t)
+}
@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"testing"
+)
+
+func TestNormaliseCardType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Hitag_16", HITAG_16},
+		{"Hitag16", HITAG_16},
+		{"hitag 16", HITAG_16},
+		{"HITAG-16", HITAG_16},
+		{"Hitag_32", HITAG_32},
+		{"Hitag32", HITAG_32},
+		{"hitag 32", HITAG_32},
+		{"Mifare", MIFARE},
+		{"mifare", MIFARE},
+		{"Legic", LEGIC},
+		{"LEGIC", LEGIC},
+		{"Virtual", VIRTUAL_CARD},
+		{"VirtualCard", VIRTUAL_CARD},
+		{"VirtualSmartCard", VIRTUAL_CARD},
+		{"virtual smart card", VIRTUAL_CARD},
+		{"Magstripe", "Magstripe"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormaliseCardType(tt.in); got != tt.want {
+			t.Errorf("NormaliseCardType(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGetCardType(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string
+		byCardNo bool
+	}{
+		{"Hitag16", HITAG_16, true},
+		{"hitag 32", HITAG_32, true},
+		{"Mifare", MIFARE, false},
+		{"Legic", LEGIC, false},
+		{"VirtualSmartCard", VIRTUAL_CARD, false},
+		{"Magstripe", "", false},
+	}
+
+	for _, tt := range tests {
+		ct := GetCardType(tt.in)
+
+		if tt.want == "" {
+			if ct != nil {
+				t.Errorf("GetCardType(%q) = %q, want nil", tt.in, ct.Name)
+			}
+			continue
+		}
+
+		if ct == nil || ct.Name != tt.want || ct.IdentifiedByCardNo != tt.byCardNo {
+			t.Errorf("GetCardType(%q) = %+v, want %s identified by card number %v", tt.in, ct, tt.want, tt.byCardNo)
+		}
+	}
+}
+
+func TestAccessDeviceMatches(t *testing.T) {
+	card := func(typ, serial, cardNo, orga string) AccessDevice {
+		return AccessDevice{SmartcardType: typ, SmartcardSerialNo: serial, SmartcardCardNo: cardNo, SmartcardOrgaNo: orga}
+	}
+
+	tests := []struct {
+		name string
+		a    AccessDevice
+		b    AccessDevice
+		want bool
+	}{
+		{"hitag16 same card number and orga, other serial", card("Hitag_16", "1", "42", "7"), card("Hitag_16", "2", "42", "7"), true},
+		{"hitag16 other card number", card("Hitag_16", "1", "42", "7"), card("Hitag_16", "1", "43", "7"), false},
+		{"hitag16 other card orga", card("Hitag_16", "1", "42", "7"), card("Hitag_16", "1", "42", "8"), false},
+		{"hitag32 same card number and orga, other serial", card("Hitag32", "1", "42", "7"), card("hitag 32", "0", "42", "7"), true},
+		{"hitag32 other card number", card("Hitag_32", "1", "42", "7"), card("Hitag_32", "1", "44", "7"), false},
+		{"mifare same serial, other card number", card("Mifare", "5", "1", "1"), card("mifare", "5", "2", "2"), true},
+		{"mifare other serial", card("Mifare", "5", "1", "1"), card("Mifare", "6", "1", "1"), false},
+		{"legic same serial", card("Legic", "9", "", ""), card("LEGIC", "9", "", ""), true},
+		{"legic other serial", card("Legic", "9", "", ""), card("Legic", "8", "", ""), false},
+		{"virtual same serial across aliases", card("Virtual", "3", "", ""), card("VirtualSmartCard", "3", "", ""), true},
+		{"virtual other serial", card("Virtual", "3", "", ""), card("Virtual", "4", "", ""), false},
+		{"unknown type by serial", card("Magstripe", "3", "1", ""), card("Magstripe", "3", "2", ""), true},
+		{"type mismatch same serial", card("Mifare", "5", "", ""), card("Legic", "5", "", ""), false},
+		{"type mismatch same card number", card("Hitag_16", "1", "42", "7"), card("Hitag_32", "1", "42", "7"), false},
+		{"type mismatch hitag and mifare", card("Hitag_16", "5", "42", "7"), card("Mifare", "5", "42", "7"), false},
+		//the former empty case "Hitag_16" left Hitag16 swipes unmatched against Hitag_16 reservations
+		{"hitag16 swipe against Hitag_16 reservation", card("Hitag16", "0", "42", "7"), card("Hitag_16", "0", "42", "7"), true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Matches(tt.b); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := tt.b.Matches(tt.a); got != tt.want {
+			t.Errorf("%s: reverse Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHitag16SwipeMatchesReservation(t *testing.T) {
+	r := new(Reservation)
+	r.AccessDevice = AccessDevice{SmartcardType: "Hitag_16", SmartcardSerialNo: "0", SmartcardCardNo: "42", SmartcardOrgaNo: "7"}
+
+	swipe := VirtualAccessDevice{SmartcardType: "Hitag16", SmartcardCardNo: "42", SmartcardOrgaNo: "7"}
+
+	if !r.AccessDevice.Matches(swipe.GetAccessDevice()) {
+		t.Fatal("Hitag16 swipe does not match its Hitag_16 reservation")
+	}
+}
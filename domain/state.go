@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// State is the snapshot saved at shutdown, a restarted simulator resumes from it.
+// Scheduled messages are not part of it, the timers are rebuilt from the trips and reservations.
+type State struct {
+	SavedTime    time.Time
+	Vehicles     []*Vehicle
+	Cards        []*Card
+	Reservations []*Reservation
+	Trips        []*Trip
+	CUCMRequests []*PendingCUCMRequest
+	Personas     *PersonaState
+	PINs         *SavedPINs
+
+	LastTaskNumber int //task numbers continue after it
+}
+
+// SavedPINs keeps the PINs that the JSON of the reservations, cards and swipes leaves out
+type SavedPINs struct {
+	Reservations map[string]string   //by ReservationId
+	Cards        map[int]string      //by position in Cards
+	Swipes       map[string][]string //attempts of the pending CUCM requests by Guid
+}
+
+// LoadState reads a saved state and links the trips back to their reservation
+func LoadState(r io.Reader) (*State, error) {
+	s := new(State)
+
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("Error reading state: %v", err)
+	}
+
+	if s.Vehicles == nil {
+		s.Vehicles = make([]*Vehicle, 0)
+	}
+	if s.Cards == nil {
+		s.Cards = make([]*Card, 0)
+	}
+	if s.Reservations == nil {
+		s.Reservations = make([]*Reservation, 0)
+	}
+	if s.Trips == nil {
+		s.Trips = make([]*Trip, 0)
+	}
+
+	for _, r := range s.Reservations {
+		for _, t := range r.Trips {
+			t.Reservation = r
+		}
+	}
+
+	if s.PINs != nil {
+		s.restorePINs()
+	}
+
+	//states saved without the last task number continue after the highest restored one
+	if s.LastTaskNumber == 0 {
+		s.LastTaskNumber = s.maxRequestId()
+	}
+
+	return s, nil
+}
+
+func (s *State) maxRequestId() int {
+	max := 0
+
+	requestId := func(id string) {
+		if n, err := strconv.Atoi(id); err == nil && n > max {
+			max = n
+		}
+	}
+
+	for _, value := range s.Reservations {
+		requestId(value.RequestId)
+	}
+	for _, value := range s.CUCMRequests {
+		if value.Swipe != nil {
+			requestId(value.Swipe.RequestId)
+		}
+	}
+
+	return max
+}
+
+func (s *State) keepPINs() {
+	s.PINs = &SavedPINs{Reservations: make(map[string]string), Cards: make(map[int]string), Swipes: make(map[string][]string)}
+
+	for _, value := range s.Reservations {
+		if value.AccessDevice.PIN != "" {
+			s.PINs.Reservations[value.ReservationId] = string(value.AccessDevice.PIN)
+		}
+	}
+	for i, value := range s.Cards {
+		if value.PIN != "" {
+			s.PINs.Cards[i] = string(value.PIN)
+		}
+	}
+	for _, value := range s.CUCMRequests {
+		if value.Swipe != nil && len(value.Swipe.PINAttempts) > 0 {
+			s.PINs.Swipes[value.Guid] = value.Swipe.PINAttempts
+		}
+	}
+}
+
+func (s *State) restorePINs() {
+	for _, value := range s.Reservations {
+		if pin, ok := s.PINs.Reservations[value.ReservationId]; ok {
+			value.AccessDevice.PIN = Secret(pin)
+		}
+	}
+	for i, value := range s.Cards {
+		if pin, ok := s.PINs.Cards[i]; ok {
+			value.PIN = Secret(pin)
+		}
+	}
+	for _, value := range s.CUCMRequests {
+		if value.Swipe != nil {
+			value.Swipe.PINAttempts = s.PINs.Swipes[value.Guid]
+		}
+	}
+}
+
+func (s *State) Save(w io.Writer) error {
+	s.keepPINs()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(s)
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"time"
+)
+
+type TaskType string
+
+const (
+	RESERVATION_TASK   TaskType = "Reservation"
+	DRIVER_SWIPE_TASK  TaskType = "DriverSwipe"
+	CUCM_RESPONSE_TASK TaskType = "CUCMResponse"
+	COMMAND_TASK       TaskType = "Command"
+)
+
+type TaskStatusChange struct {
+	Status TaskStatus
+	Time   time.Time
+}
+
+type Task struct {
+	TaskNumber string
+	Type       TaskType
+	OrgaNo     string
+	Request    RequestI
+	History    []*TaskStatusChange
+
+	ReservationId   string `json:",omitempty"`
+	SwipeTaskNumber string `json:",omitempty"` //swipe a CUCM response answers
+}
+
+func NewTask(taskNumber string, tt TaskType, r RequestI, now time.Time) *Task {
+	t := new(Task)
+
+	t.TaskNumber = taskNumber
+	t.Type = tt
+	t.OrgaNo = r.GetOrgaNo()
+	t.Request = r
+	t.History = []*TaskStatusChange{&TaskStatusChange{Status: r.GetTechStatus(), Time: now}}
+
+	return t
+}
+
+func (t *Task) AddStatus(s TaskStatus, now time.Time) {
+	t.History = append(t.History, &TaskStatusChange{Status: s, Time: now})
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type Feature string
+
+const (
+	RESERVATIONS  Feature = "reservations"
+	VIRTUAL_CARDS Feature = "virtualCards"
+	CUCM          Feature = "cucm"
+	COMMANDS      Feature = "commands"
+
+	DEFAULT_INTERFACE_VERSION = "21"
+)
+
+var features = []Feature{RESERVATIONS, VIRTUAL_CARDS, CUCM, COMMANDS}
+
+type Tenant struct {
+	OrgaNo           string
+	BaseURL          string
+	InterfaceVersion string
+	Username         string
+	Password         string
+	Features         []Feature
+}
+
+func NewTenant(orgaNo string, baseURL string) *Tenant {
+	t := new(Tenant)
+
+	t.OrgaNo = orgaNo
+	t.BaseURL = baseURL
+	t.InterfaceVersion = DEFAULT_INTERFACE_VERSION
+
+	return t
+}
+
+// Validate rejects tenants without OrgaNo or BaseURL and misspelled features, which would disable the feature silently
+func (t *Tenant) Validate() error {
+	if t.OrgaNo == "" || t.BaseURL == "" {
+		return fmt.Errorf("Tenant configuration requires OrgaNo and BaseURL")
+	}
+
+	for _, value := range t.Features {
+		known := false
+		for _, f := range features {
+			known = known || value == f
+		}
+
+		if !known {
+			return fmt.Errorf("Unknown feature %s for orga %s", value, t.OrgaNo)
+		}
+	}
+
+	return nil
+}
+
+func (t *Tenant) HasFeature(f Feature) bool {
+	//no feature list means everything is enabled
+	if len(t.Features) == 0 {
+		return true
+	}
+
+	for _, value := range t.Features {
+		if value == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Tenant) GetEndpoint(v *InterfaceVersion, path string) string {
+	return t.BaseURL + "/ws/invers/" + v.Number + "/" + t.OrgaNo + "/" + path
+}
+
+// tenants file format: [{"OrgaNo": "1234", "BaseURL": "http://...", "Features": ["reservations", ...]}, ...]
+func LoadTenants(r io.Reader) ([]*Tenant, error) {
+	tenants := make([]*Tenant, 0)
+
+	if err := json.NewDecoder(r).Decode(&tenants); err != nil {
+		return nil, fmt.Errorf("Error reading tenants: %v", err)
+	}
+
+	for _, t := range tenants {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+
+		if t.InterfaceVersion == "" {
+			t.InterfaceVersion = DEFAULT_INTERFACE_VERSION
+		} else if GetInterfaceVersion(t.InterfaceVersion) == nil {
+			return nil, fmt.Errorf("Unsupported interface version %s for orga %s", t.InterfaceVersion, t.OrgaNo)
+		}
+	}
+
+	return tenants, nil
+}
@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadTenantsRejectsUnknownFeatures(t *testing.T) {
+	_, err := LoadTenants(strings.NewReader(`[{"OrgaNo": "1234", "BaseURL": "http://tako", "Features": ["reservations", "virtualcards"]}]`))
+
+	if err == nil || !strings.Contains(err.Error(), "1234") || !strings.Contains(err.Error(), "virtualcards") {
+		t.Errorf("LoadTenants() = %v", err)
+	}
+
+	tenants, err := LoadTenants(strings.NewReader(`[{"OrgaNo": "1234", "BaseURL": "http://tako", "Features": ["reservations", "virtualCards", "cucm", "commands"]}]`))
+	if err != nil || len(tenants) != 1 {
+		t.Errorf("LoadTenants() = %v, %v", tenants, err)
+	}
+}
@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DEFAULT_TIMEZONE = 20
+
+// Invers timezone codes follow the Microsoft time zone index values.
+var timezones = map[int]string{
+	0:   "Etc/GMT+12", //a missing timezone element reads as 0 too, reservations without one get the default instead
+	1:   "Pacific/Pago_Pago",
+	2:   "Pacific/Honolulu",
+	3:   "America/Anchorage",
+	4:   "America/Los_Angeles",
+	10:  "America/Denver",
+	13:  "America/Chihuahua",
+	15:  "America/Phoenix",
+	20:  "America/Chicago",
+	25:  "America/Regina",
+	30:  "America/Mexico_City",
+	33:  "America/Guatemala",
+	35:  "America/New_York",
+	40:  "America/Indiana/Indianapolis",
+	45:  "America/Bogota",
+	50:  "America/Halifax",
+	55:  "America/La_Paz",
+	56:  "America/Santiago",
+	60:  "America/St_Johns",
+	65:  "America/Sao_Paulo",
+	70:  "America/Cayenne",
+	73:  "America/Nuuk",
+	75:  "Atlantic/South_Georgia",
+	80:  "Atlantic/Azores",
+	83:  "Atlantic/Cape_Verde",
+	85:  "Europe/London",
+	90:  "Atlantic/Reykjavik",
+	95:  "Europe/Budapest",
+	100: "Europe/Warsaw",
+	105: "Europe/Paris",
+	110: "Europe/Berlin",
+	113: "Africa/Lagos",
+	115: "Europe/Bucharest",
+	120: "Africa/Cairo",
+	125: "Europe/Helsinki",
+	130: "Europe/Athens",
+	135: "Asia/Jerusalem",
+	140: "Africa/Johannesburg",
+	145: "Europe/Moscow",
+	150: "Asia/Riyadh",
+	155: "Africa/Nairobi",
+	158: "Asia/Baghdad",
+	160: "Asia/Tehran",
+	165: "Asia/Dubai",
+	170: "Asia/Yerevan",
+	175: "Asia/Kabul",
+	180: "Asia/Yekaterinburg",
+	185: "Asia/Tashkent",
+	190: "Asia/Kolkata",
+	193: "Asia/Kathmandu",
+	195: "Asia/Almaty",
+	200: "Asia/Colombo",
+	201: "Asia/Novosibirsk",
+	203: "Asia/Yangon",
+	205: "Asia/Bangkok",
+	207: "Asia/Krasnoyarsk",
+	210: "Asia/Shanghai",
+	215: "Asia/Singapore",
+	220: "Asia/Taipei",
+	225: "Australia/Perth",
+	227: "Asia/Irkutsk",
+	230: "Asia/Seoul",
+	235: "Asia/Tokyo",
+	240: "Asia/Yakutsk",
+	245: "Australia/Darwin",
+	250: "Australia/Adelaide",
+	255: "Australia/Sydney",
+	260: "Australia/Brisbane",
+	265: "Australia/Hobart",
+	270: "Asia/Vladivostok",
+	275: "Pacific/Port_Moresby",
+	280: "Pacific/Guadalcanal",
+	285: "Pacific/Fiji",
+	290: "Pacific/Auckland",
+	300: "Pacific/Tongatapu",
+}
+
+// Timezones is the timezone table of a simulator, the default mapping with the codes added or replaced
+// by the configuration
+type Timezones struct {
+	mutex     sync.Mutex
+	codes     map[int]string
+	locations map[int]*time.Location
+}
+
+func NewTimezones(codes map[int]string) (*Timezones, error) {
+	tz := new(Timezones)
+	tz.codes = make(map[int]string, len(timezones)+len(codes))
+	tz.locations = make(map[int]*time.Location)
+
+	for code, name := range timezones {
+		tz.codes[code] = name
+	}
+
+	for code, name := range codes {
+		if _, err := time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("Invalid timezone %q for code %d: %v", name, code, err)
+		}
+
+		tz.codes[code] = name
+	}
+
+	return tz, nil
+}
+
+func (tz *Timezones) GetLocation(code int) *time.Location {
+	tz.mutex.Lock()
+	defer tz.mutex.Unlock()
+
+	if loc, ok := tz.locations[code]; ok {
+		return loc
+	}
+
+	loc := time.UTC
+
+	if name, ok := tz.codes[code]; !ok {
+		fmt.Println("WARNING: unknown timezone code", code, "- falling back to UTC")
+	} else if l, err := time.LoadLocation(name); err != nil {
+		fmt.Println("WARNING: cannot load timezone", name, "for code", code, "- falling back to UTC:", err)
+	} else {
+		loc = l
+	}
+
+	tz.locations[code] = loc
+
+	return loc
+}
+
+// GetCodes returns a copy of the timezone mapping
+func (tz *Timezones) GetCodes() map[int]string {
+	tz.mutex.Lock()
+	defer tz.mutex.Unlock()
+
+	codes := make(map[int]string, len(tz.codes))
+	for code, name := range tz.codes {
+		codes[code] = name
+	}
+
+	return codes
+}
+
+// mapping file format: {"20": "America/Chicago", ...}
+func ReadTimezones(r io.Reader) (map[int]string, error) {
+	mapping := make(map[string]string)
+
+	if err := json.NewDecoder(r).Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("Error reading timezone mapping: %v", err)
+	}
+
+	codes := make(map[int]string)
+	for key, name := range mapping {
+		code, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid timezone code %q: %v", key, err)
+		}
+
+		codes[code] = name
+	}
+
+	return codes, nil
+}
@@ -2,7 +2,6 @@ package domain
 
 import (
 	"fmt"
-	"math/rand"
 	"time"
 )
 
@@ -21,6 +20,8 @@ const (
 	TRIP_START       EventName = "TripStartFromDevice"
 	TRIP_END         EventName = "TripEndFromDevice"
 	TRIP_COMPLETE    EventName = "TripFinished"
+	DOOR_OPENED      EventName = "DoorOpened"
+	DOOR_CLOSED      EventName = "DoorClosed"
 
 	REJECTED_ACCESS EventName = "RejectedAccess"
 	LATE_DRIVER     EventName = "DelayedTripEnd"
@@ -28,10 +29,12 @@ const (
 
 type Trip struct {
 	Reservation    *Reservation `json:"-"`
+	Vehicle        *Vehicle     `json:"-"`
 	VehicleDevice  VehicleDevice
 	AccessDevice   AccessDevice
 	ReservationId  string
 	TripId         string
+	TripNo         int
 	StartTime      time.Time
 	EndTime        time.Time
 	OdoStart       int
@@ -39,14 +42,31 @@ type Trip struct {
 	Status         TripStatus
 	IgnitionStatus bool
 	IgnitionChange time.Time
+	KeepDataFob    bool
+	PINTries       int
+	LateAlarms     int
+	Late           bool
+	DelayMinutes   int
+
+	Illegal            bool
+	EmergencyTrip      bool
+	EmergencyReason    string
+	WithoutReservation bool
+	PlannedEndTime     time.Time
 }
 
 type DriverSwipe struct {
-	CUCMGuid      string
-	TechStatus    TaskStatus
-	RequestId     string              `xml:"Body>SendVirtualSmartCard>task>TaskNumber"`
-	VehicleDevice VehicleDevice       `xml:"Body>SendVirtualSmartCard>task>Destination"`
-	AccessDevice  VirtualAccessDevice `xml:"Body>SendVirtualSmartCard>task>VirtualSmartCard"`
+	CUCMGuid        string
+	TechStatus      TaskStatus
+	TaskError       TaskError
+	RejectionReason RejectionReason
+	PINResult       PINResult
+	PINTries        int
+	Vehicle         *Vehicle            `json:"-"`
+	RequestId       string              `xml:"Body>SendVirtualSmartCard>task>TaskNumber"`
+	VehicleDevice   VehicleDevice       `xml:"Body>SendVirtualSmartCard>task>Destination"`
+	AccessDevice    VirtualAccessDevice `xml:"Body>SendVirtualSmartCard>task>VirtualSmartCard"`
+	PINAttempts     []string            `xml:"Body>SendVirtualSmartCard>task>PINAttempts>PIN" json:"-"` //simulator extension, the PINs typed by the driver
 }
 
 type VirtualAccessDevice struct {
@@ -60,6 +80,7 @@ type CUCMResponse struct {
 	Guid          string `xml:"Body>AnswerRequest>guid"`
 	Timezone      int    `xml:"Body>AnswerRequest>taskList>Task>Reservation>Start>Timezone"`
 	TechStatus    TaskStatus
+	TaskError     TaskError
 	VehicleDevice VehicleDevice `xml:"Body>AnswerRequest>taskList>Task>Destination"`
 	AccessDevice  AccessDevice  `xml:"Body>AnswerRequest>taskList>Task>Reservation>UserAccessList>UserAccess"`
 	ReservationId string        `xml:"Body>AnswerRequest>taskList>Task>Reservation>ReservationNo"`
@@ -70,10 +91,31 @@ type CUCMResponse struct {
 	LateBuffer    int           `xml:"Body>AnswerRequest>taskList>Task>Reservation>ReturnOptions>DelayTime"`
 }
 
+func (a VirtualAccessDevice) GetAccessDevice() AccessDevice {
+	return AccessDevice{
+		SmartcardSerialNo: a.SmartcardSerialNo,
+		SmartcardCardNo:   a.SmartcardCardNo,
+		SmartcardOrgaNo:   a.SmartcardOrgaNo,
+		SmartcardType:     a.SmartcardType,
+	}
+}
+
 func (r *DriverSwipe) GetTechStatus() TaskStatus {
 	return r.TechStatus
 }
 
+func (r *DriverSwipe) GetTaskError() TaskError {
+	if r.TaskError == "" {
+		return NO_ERROR
+	}
+
+	return r.TaskError
+}
+
+func (r *DriverSwipe) SetTaskError(te TaskError) {
+	r.TaskError = te
+}
+
 func (r *DriverSwipe) GetRequestId() string {
 	return r.RequestId
 }
@@ -82,27 +124,35 @@ func (r *DriverSwipe) GetOrgaNo() string {
 	return r.VehicleDevice.OrgaNo
 }
 
-func (r *DriverSwipe) GenerateStatus() string {
-	return generateStatus(RequestI(r))
+func (r *DriverSwipe) GetVehicleDevice() VehicleDevice {
+	return r.VehicleDevice
+}
+
+func (r *DriverSwipe) GetTimezoneCode(e *Environment) int {
+	return e.Settings.DefaultTimezone
+}
+
+func (r *DriverSwipe) GenerateStatus(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateStatus(e, v, now, RequestI(r))
 }
 
-func (r *DriverSwipe) GenerateTaskNumber() {
-	r.RequestId = fmt.Sprint(rand.Intn(1000000))
+func (r *DriverSwipe) SetRequestId(id string) {
+	r.RequestId = id
 }
 
-func (ds *DriverSwipe) GenerateResponse() string {
+func (ds *DriverSwipe) GenerateResponse(e *Environment, now time.Time) string {
 	return "<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"\n\t<s:Body>" +
 		"\n\t\t<SendVirtualSmartCardResponse xmlns=\"http://tempuri.org/\">" +
 		"\n\t\t\t<SendVirtualSmartCardResult xmlns:a=\"http://invers.com\" xmlns:i=\"http://www.w3.org/2001/XMLSchema-instance\">" +
-		"\n\t\t\t\t<a:CustomerId>00000000-0000-0000-0000-000000000000</a:CustomerId>" +
+		"\n\t\t\t\t<a:CustomerId>" + e.Settings.Identifiers.CustomerId + "</a:CustomerId>" +
 		"\n\t\t\t\t<a:DataStatus>Sending</a:DataStatus>" +
-		"\n\t\t\t\t<a:TaskError>NoError</a:TaskError>" +
+		"\n\t\t\t\t<a:TaskError>" + fmt.Sprint(ds.GetTaskError()) + "</a:TaskError>" +
 		"\n\t\t\t\t<a:TaskNumber>" + ds.GetRequestId() + "</a:TaskNumber>" +
 		"\n\t\t\t\t<a:TaskSendStatus>" + fmt.Sprint(ds.GetTechStatus()) + "</a:TaskSendStatus>" +
 		"\n\t\t\t\t<a:Timestamp xmlns:b=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\">" +
-		"\n\t\t\t\t\t<b:Timezone>20</b:Timezone>" +
-		"\n\t\t\t\t\t<b:UTCDateTime>" + time.Now().UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
+		"\n\t\t\t\t\t<b:Timezone>" + fmt.Sprint(ds.GetTimezoneCode(e)) + "</b:Timezone>" +
+		"\n\t\t\t\t\t<b:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
 		"\n\t\t\t\t</a:Timestamp>" +
 		"\n\t\t\t\t<a:UsedCommsystem>Unknown</a:UsedCommsystem>" +
 		"\n\t\t\t</SendVirtualSmartCardResult>" +
@@ -111,14 +161,26 @@ func (ds *DriverSwipe) GenerateResponse() string {
 		"\n</s:Envelope>"
 }
 
-func (cr *CUCMResponse) GenerateTaskNumber() {
-	cr.RequestId = fmt.Sprint(rand.Intn(1000000))
+func (cr *CUCMResponse) SetRequestId(id string) {
+	cr.RequestId = id
 }
 
 func (cr *CUCMResponse) GetTechStatus() TaskStatus {
 	return cr.TechStatus
 }
 
+func (cr *CUCMResponse) GetTaskError() TaskError {
+	if cr.TaskError == "" {
+		return NO_ERROR
+	}
+
+	return cr.TaskError
+}
+
+func (cr *CUCMResponse) SetTaskError(te TaskError) {
+	cr.TaskError = te
+}
+
 func (cr *CUCMResponse) GetRequestId() string {
 	return cr.RequestId
 }
@@ -127,24 +189,32 @@ func (cr *CUCMResponse) GetOrgaNo() string {
 	return cr.VehicleDevice.OrgaNo
 }
 
-func (cr *CUCMResponse) GenerateStatus() string {
-	return generateStatus(RequestI(cr))
+func (cr *CUCMResponse) GetVehicleDevice() VehicleDevice {
+	return cr.VehicleDevice
+}
+
+func (cr *CUCMResponse) GetTimezoneCode(e *Environment) int {
+	return cr.Timezone
+}
+
+func (cr *CUCMResponse) GenerateStatus(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateStatus(e, v, now, RequestI(cr))
 }
 
-func (cr *CUCMResponse) GenerateResponse() string {
+func (cr *CUCMResponse) GenerateResponse(e *Environment, now time.Time) string {
 	return "<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"\n\t<s:Body>" +
 		"\n\t\t<AnswerRequestResponse xmlns=\"http://tempuri.org/\">" +
 		"\n\t\t\t<AnswerRequestResult xmlns:a=\"http://invers.com\" xmlns:i=\"http://www.w3.org/2001/XMLSchema-instance\">" +
 		"\n\t\t\t\t<a:TaskStatus>" +
-		"\n\t\t\t\t\t<a:CustomerId>00000000-0000-0000-0000-000000000000</a:CustomerId>" +
+		"\n\t\t\t\t\t<a:CustomerId>" + e.Settings.Identifiers.CustomerId + "</a:CustomerId>" +
 		"\n\t\t\t\t\t<a:DataStatus>Sending</a:DataStatus>" +
-		"\n\t\t\t\t\t<a:TaskError>NoError</a:TaskError>" +
+		"\n\t\t\t\t\t<a:TaskError>" + fmt.Sprint(cr.GetTaskError()) + "</a:TaskError>" +
 		"\n\t\t\t\t\t<a:TaskNumber>" + cr.RequestId + "</a:TaskNumber>" +
 		"\n\t\t\t\t\t<a:TaskSendStatus>" + fmt.Sprint(cr.TechStatus) + "</a:TaskSendStatus>" +
 		"\n\t\t\t\t\t<a:Timestamp xmlns:b=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\">" +
-		"\n\t\t\t\t\t\t<b:Timezone>20</b:Timezone>" +
-		"\n\t\t\t\t\t\t<b:UTCDateTime>" + time.Now().UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
+		"\n\t\t\t\t\t\t<b:Timezone>" + fmt.Sprint(cr.GetTimezoneCode(e)) + "</b:Timezone>" +
+		"\n\t\t\t\t\t\t<b:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05.0000000Z") + "</b:UTCDateTime>" +
 		"\n\t\t\t\t\t</a:Timestamp>" +
 		"\n\t\t\t\t\t<a:UsedCommsystem>Unknown</a:UsedCommsystem>" +
 		"\n\t\t\t\t</a:TaskStatus>" +
@@ -154,39 +224,55 @@ func (cr *CUCMResponse) GenerateResponse() string {
 		"\n</s:Envelope>"
 }
 
-func (ds *DriverSwipe) GenerateRejectedAccess() string {
-	return generateProblemEvent(REJECTED_ACCESS, nil, ds)
+func (ds *DriverSwipe) GenerateRejectedAccess(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateProblemEvent(e, v, now, REJECTED_ACCESS, nil, ds)
 }
 
-func (t *Trip) GenerateDriverLate() string {
-	return generateProblemEvent(LATE_DRIVER, t, nil)
+func (t *Trip) GenerateDriverLate(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return generateProblemEvent(e, v, now, LATE_DRIVER, t, nil)
 }
 
-func (t *Trip) GenerateTripStart() string {
-	return t.generateEvent(TRIP_START)
+func (t *Trip) GenerateTripStart(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return t.generateEvent(e, v, now, TRIP_START, false)
 }
 
-func (t *Trip) GenerateFirstIgnition() string {
-	return t.generateEvent(FIRST_IGNITION)
+func (t *Trip) GenerateFirstIgnition(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return t.generateEvent(e, v, now, FIRST_IGNITION, false)
 }
 
-func (t *Trip) GenerateDataFobAction(removed bool) string {
+func (t *Trip) GenerateDataFobAction(e *Environment, v *InterfaceVersion, now time.Time, removed bool) string {
 	if removed {
-		return t.generateEvent(DATAFOB_REMOVED)
+		return t.generateEvent(e, v, now, DATAFOB_REMOVED, false)
+	} else {
+		return t.generateEvent(e, v, now, DATAFOB_RETURNED, false)
+	}
+}
+
+func (t *Trip) GenerateLockAction(e *Environment, v *InterfaceVersion, now time.Time, locked bool) string {
+	if locked {
+		return t.generateEvent(e, v, now, DOORS_LOCKED, false)
+	} else {
+		return t.generateEvent(e, v, now, DOORS_UNLOCKED, false)
+	}
+}
+
+func (t *Trip) GenerateDoorAction(e *Environment, v *InterfaceVersion, now time.Time, open bool) string {
+	if open {
+		return t.generateEvent(e, v, now, DOOR_OPENED, false)
 	} else {
-		return t.generateEvent(DATAFOB_RETURNED)
+		return t.generateEvent(e, v, now, DOOR_CLOSED, false)
 	}
 }
 
-func (t *Trip) GenerateTripEnd() string {
-	return t.generateEvent(TRIP_END)
+func (t *Trip) GenerateTripEnd(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return t.generateEvent(e, v, now, TRIP_END, false)
 }
 
-func (t *Trip) GenerateTripSegment() string {
+func (t *Trip) GenerateTripSegment(e *Environment, v *InterfaceVersion, now time.Time) string {
 	var tripSegment string
 	var keyValue string
 
-	loc := t.Reservation.GetTimezone()
+	loc := t.getTimezone(e)
 
 	if t.IgnitionStatus == false {
 		keyValue = "17" //OFF
@@ -196,7 +282,7 @@ func (t *Trip) GenerateTripSegment() string {
 
 	tripSegment = "<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"	<soap:Body>" +
-		"		<ns4:RawSegmentEvaluated xmlns=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface\" xmlns:ns2=\"http://invers.com\" xmlns:ns3=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\" xmlns:ns4=\"http://tempuri.org/\" xmlns:ns5=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns6=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
+		"		<ns4:RawSegmentEvaluated xmlns=\"" + v.IcsNamespace + "\" xmlns:ns2=\"" + v.InversNamespace + "\" xmlns:ns3=\"" + v.DataTypesNamespace + "\" xmlns:ns4=\"http://tempuri.org/\" xmlns:ns5=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns6=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
 		"			<ns4:segment>" +
 		"				<ns2:AdditionalParameters>" +
 		"					<list>" +
@@ -207,15 +293,18 @@ func (t *Trip) GenerateTripSegment() string {
 		"						</AdditionalParameter>" +
 		"					</list>" +
 		"				</ns2:AdditionalParameters>" +
-		"				<ns2:ComputedDrivingDistance>" + fmt.Sprint(3) + "</ns2:ComputedDrivingDistance>" +
+		"				<ns2:ComputedDrivingDistance>" + fmt.Sprint(e.Settings.Odometer.SegmentDistance) + "</ns2:ComputedDrivingDistance>" +
 		"				<ns2:ComputedStartMileage>" + fmt.Sprint(t.OdoStart) + "</ns2:ComputedStartMileage>" +
 		"				<ns2:ComputedStopMileage>" + fmt.Sprint(t.OdoEnd) + "</ns2:ComputedStopMileage>" +
 		"				<ns2:DistanceConversionFactor>1.0</ns2:DistanceConversionFactor>" +
-		"				<ns2:DrivingDistance>" + fmt.Sprint(3) + "</ns2:DrivingDistance>" +
+		"				<ns2:DrivingDistance>" + fmt.Sprint(e.Settings.Odometer.SegmentDistance) + "</ns2:DrivingDistance>" +
 		"				<ns2:Driver>true</ns2:Driver>" +
+		"				<ns2:EmergencyReason>" + t.GetEmergencyReason() + "</ns2:EmergencyReason>" +
+		"				<ns2:EmergencyTrip>" + fmt.Sprint(t.EmergencyTrip) + "</ns2:EmergencyTrip>" +
 		"				<ns2:EnterPassengerCount>0</ns2:EnterPassengerCount>" +
-		"				<ns2:Fuel>100</ns2:Fuel>" +
-		"				<ns2:Id>654</ns2:Id>" +
+		"				<ns2:Fuel>" + fmt.Sprint(t.Vehicle.Fuel) + "</ns2:Fuel>" +
+		"				<ns2:Id>" + e.Settings.Identifiers.SegmentId + "</ns2:Id>" +
+		"				<ns2:Illegal>" + fmt.Sprint(t.Illegal) + "</ns2:Illegal>" +
 		"				<ns2:JobType>Unknown</ns2:JobType>" +
 		"				<ns2:NewTrip>false</ns2:NewTrip>" +
 		"				<ns2:PassengerCount>0</ns2:PassengerCount>" +
@@ -226,7 +315,7 @@ func (t *Trip) GenerateTripSegment() string {
 		"					<OrgaNo>" + t.VehicleDevice.OrgaNo + "</OrgaNo>" +
 		"					<Type>BCSA</Type>" +
 		"				</ns2:ReservationItem>" +
-		"				<ns2:ReservationNo>" + t.ReservationId + "</ns2:ReservationNo>" +
+		"				<ns2:ReservationNo>" + t.getReservationNo() + "</ns2:ReservationNo>" +
 		"				<ns2:ReservationType>0</ns2:ReservationType>" +
 		"				<ns2:SentStatus>Sending</ns2:SentStatus>" +
 		"				<ns2:Source>" +
@@ -267,30 +356,30 @@ func (t *Trip) GenerateTripSegment() string {
 		"					<ns2:DestinationType>IBOXX</ns2:DestinationType>" +
 		"					<ns2:Firmwareversion/>" +
 		"					<ns2:OrgaNo>" + t.VehicleDevice.OrgaNo + "</ns2:OrgaNo>" +
-		"					<ns2:SourceNo>95539211389632515</ns2:SourceNo>" +
+		"					<ns2:SourceNo>" + t.Vehicle.SourceNo + "</ns2:SourceNo>" +
 		"				</ns2:Source>" +
-		"				<ns2:Start>" + t.StartTime.In(loc).Format("2006-01-02T15:04:05") + "</ns2:Start>" +
+		"				<ns2:Start>" + v.FormatLocalTime(t.StartTime, loc) + "</ns2:Start>" +
 		"				<ns2:StartGPS>" +
 		"					<ns2:Altitude>0.0</ns2:Altitude>" +
 		"					<ns2:Distance>0</ns2:Distance>" +
 		"					<ns2:Format>ddd_dddddd</ns2:Format>" +
-		"					<ns2:Latitude>51.493905</ns2:Latitude>" +
+		"					<ns2:Latitude>" + fmt.Sprint(t.Vehicle.Latitude) + "</ns2:Latitude>" +
 		"					<ns2:LatitudeHemisphere>32</ns2:LatitudeHemisphere>" +
-		"					<ns2:Longitude>-0.10749166666666667</ns2:Longitude>" +
+		"					<ns2:Longitude>" + fmt.Sprint(t.Vehicle.Longitude) + "</ns2:Longitude>" +
 		"					<ns2:LongitudeHemisphere>32</ns2:LongitudeHemisphere>" +
 		"					<ns2:Quality>1</ns2:Quality>" +
 		"					<ns2:SatInUse>8</ns2:SatInUse>" +
 		"					<ns2:Timestamp>2015-04-28T04:49:43</ns2:Timestamp>" +
 		"				</ns2:StartGPS>" +
 		"				<ns2:StartMileage>" + fmt.Sprint(t.OdoStart) + "</ns2:StartMileage>" +
-		"				<ns2:Stop>" + t.EndTime.In(loc).Format("2006-01-02T15:04:05") + "</ns2:Stop>" +
+		"				<ns2:Stop>" + v.FormatLocalTime(t.EndTime, loc) + "</ns2:Stop>" +
 		"				<ns2:StopGPS>" +
 		"					<ns2:Altitude>0.0</ns2:Altitude>" +
 		"					<ns2:Distance>0</ns2:Distance>" +
 		"					<ns2:Format>ddd_dddddd</ns2:Format>" +
-		"					<ns2:Latitude>51.49389166666666</ns2:Latitude>" +
+		"					<ns2:Latitude>" + fmt.Sprint(t.Vehicle.Latitude) + "</ns2:Latitude>" +
 		"					<ns2:LatitudeHemisphere>32</ns2:LatitudeHemisphere>" +
-		"					<ns2:Longitude>-0.107495</ns2:Longitude>" +
+		"					<ns2:Longitude>" + fmt.Sprint(t.Vehicle.Longitude) + "</ns2:Longitude>" +
 		"					<ns2:LongitudeHemisphere>32</ns2:LongitudeHemisphere>" +
 		"					<ns2:Quality>1</ns2:Quality>" +
 		"					<ns2:SatInUse>9</ns2:SatInUse>" +
@@ -298,13 +387,13 @@ func (t *Trip) GenerateTripSegment() string {
 		"				</ns2:StopGPS>" +
 		"				<ns2:StopMileage>" + fmt.Sprint(t.OdoEnd) + "</ns2:StopMileage>" +
 		"				<ns2:SystemTimestamp>" +
-		"					<ns3:Timezone>20</ns3:Timezone>" +
-		"					<ns3:UTCDateTime>" + time.Now().UTC().Format("2006-01-02T15:04:05.0000000Z") + "</ns3:UTCDateTime>" + //2015-05-01T07:20:20.2299095-05:00
+		"					<ns3:Timezone>" + fmt.Sprint(t.getTimezoneCode(e)) + "</ns3:Timezone>" +
+		"					<ns3:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05.0000000Z") + "</ns3:UTCDateTime>" + //2015-05-01T07:20:20.2299095-05:00
 		"				</ns2:SystemTimestamp>" +
 		"				<ns2:Tlv/>" +
-		"				<ns2:TripNo>1</ns2:TripNo>" +
+		"				<ns2:TripNo>" + fmt.Sprint(t.TripNo) + "</ns2:TripNo>" +
 		"				<ns2:UserAccess>" +
-		"					<ns2:CardExtension>32</ns2:CardExtension>" +
+		"					<ns2:CardExtension>" + fmt.Sprint(e.Settings.Identifiers.CardExtension) + "</ns2:CardExtension>" +
 		"					<ns2:CardNo>" + t.AccessDevice.SmartcardCardNo + "</ns2:CardNo>" +
 		"					<ns2:CardOrga>" + t.AccessDevice.SmartcardOrgaNo + "</ns2:CardOrga>" +
 		"					<ns2:CocosSerialNo>0</ns2:CocosSerialNo>" +
@@ -315,6 +404,7 @@ func (t *Trip) GenerateTripSegment() string {
 		"					<ns2:TempPIN/>" +
 		"					<ns2:Type>" + t.AccessDevice.SmartcardType + "</ns2:Type>" +
 		"				</ns2:UserAccess>" +
+		"				<ns2:WithoutReservation>" + fmt.Sprint(t.WithoutReservation) + "</ns2:WithoutReservation>" +
 		"			</ns4:segment>" +
 		"		</ns4:RawSegmentEvaluated>" +
 		"	</soap:Body>" +
@@ -323,7 +413,7 @@ func (t *Trip) GenerateTripSegment() string {
 	return tripSegment
 }
 
-func (t *Trip) GenerateTripData() string {
+func (t *Trip) GenerateTripData(e *Environment, v *InterfaceVersion, now time.Time) string {
 	var tripData string
 
 	didNotDrive := "false"
@@ -331,11 +421,11 @@ func (t *Trip) GenerateTripData() string {
 		didNotDrive = "true"
 	}
 
-	loc := t.Reservation.GetTimezone()
+	loc := t.getTimezone(e)
 
 	tripData = "<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"	<soap:Body>" +
-		"		<ns4:RawTripEvaluated xmlns=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface\" xmlns:ns2=\"http://invers.com\" xmlns:ns3=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\" xmlns:ns4=\"http://tempuri.org/\" xmlns:ns5=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns6=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
+		"		<ns4:RawTripEvaluated xmlns=\"" + v.IcsNamespace + "\" xmlns:ns2=\"" + v.InversNamespace + "\" xmlns:ns3=\"" + v.DataTypesNamespace + "\" xmlns:ns4=\"http://tempuri.org/\" xmlns:ns5=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns6=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
 		"			<ns4:trip>" +
 		"				<ns2:AdditionalParameters>" +
 		"					<list>" +
@@ -344,6 +434,7 @@ func (t *Trip) GenerateTripData() string {
 		"							<ParamType>Int32</ParamType>" +
 		"							<Value>4</Value>" +
 		"						</AdditionalParameter>" +
+		t.generateDelayParameter() +
 		"					</list>" +
 		"				</ns2:AdditionalParameters>" +
 		"				<ns2:AdjustmentDistance>0</ns2:AdjustmentDistance>" +
@@ -353,10 +444,10 @@ func (t *Trip) GenerateTripData() string {
 		"				<ns2:ComputedStopMileage>" + fmt.Sprint(t.OdoEnd) + "</ns2:ComputedStopMileage>" +
 		"				<ns2:DistanceConversionFactor>1.0</ns2:DistanceConversionFactor>" +
 		"				<ns2:DrivingDistance>0</ns2:DrivingDistance>" +
-		"				<ns2:EmergencyReason>NoEmergencyTrip</ns2:EmergencyReason>" +
-		"				<ns2:EmergencyTrip>false</ns2:EmergencyTrip>" +
-		"				<ns2:Fuel>100</ns2:Fuel>" +
-		"				<ns2:Illegal>false</ns2:Illegal>" +
+		"				<ns2:EmergencyReason>" + t.GetEmergencyReason() + "</ns2:EmergencyReason>" +
+		"				<ns2:EmergencyTrip>" + fmt.Sprint(t.EmergencyTrip) + "</ns2:EmergencyTrip>" +
+		"				<ns2:Fuel>" + fmt.Sprint(t.Vehicle.Fuel) + "</ns2:Fuel>" +
+		"				<ns2:Illegal>" + fmt.Sprint(t.Illegal) + "</ns2:Illegal>" +
 		"				<ns2:NewTrip>true</ns2:NewTrip>" +
 		"				<ns2:ReservationItem>" +
 		"					<ID>0</ID>" +
@@ -364,7 +455,7 @@ func (t *Trip) GenerateTripData() string {
 		"					<OrgaNo>" + t.VehicleDevice.OrgaNo + "</OrgaNo>" +
 		"					<Type>BCSA</Type>" +
 		"				</ns2:ReservationItem>" +
-		"				<ns2:ReservationNo>" + t.ReservationId + "</ns2:ReservationNo>" +
+		"				<ns2:ReservationNo>" + t.getReservationNo() + "</ns2:ReservationNo>" +
 		"				<ns2:ReservationType>0</ns2:ReservationType>" +
 		"				<ns2:SentStatus>Sending</ns2:SentStatus>" +
 		"				<ns2:Source>" +
@@ -405,30 +496,30 @@ func (t *Trip) GenerateTripData() string {
 		"					<ns2:DestinationType>IBOXX</ns2:DestinationType>" +
 		"					<ns2:Firmwareversion/>" +
 		"					<ns2:OrgaNo>" + t.VehicleDevice.OrgaNo + "</ns2:OrgaNo>" +
-		"					<ns2:SourceNo>95539211389632515</ns2:SourceNo>" +
+		"					<ns2:SourceNo>" + t.Vehicle.SourceNo + "</ns2:SourceNo>" +
 		"				</ns2:Source>" +
-		"				<ns2:Start>" + t.StartTime.In(loc).Format("2006-01-02T15:04:05") + "</ns2:Start>" +
+		"				<ns2:Start>" + v.FormatLocalTime(t.StartTime, loc) + "</ns2:Start>" +
 		"				<ns2:StartGPS>" +
 		"					<ns2:Altitude>0.0</ns2:Altitude>" +
 		"					<ns2:Distance>0</ns2:Distance>" +
 		"					<ns2:Format>ddd_dddddd</ns2:Format>" +
-		"					<ns2:Latitude>51.493905</ns2:Latitude>" +
+		"					<ns2:Latitude>" + fmt.Sprint(t.Vehicle.Latitude) + "</ns2:Latitude>" +
 		"					<ns2:LatitudeHemisphere>32</ns2:LatitudeHemisphere>" +
-		"					<ns2:Longitude>-0.10749166666666667</ns2:Longitude>" +
+		"					<ns2:Longitude>" + fmt.Sprint(t.Vehicle.Longitude) + "</ns2:Longitude>" +
 		"					<ns2:LongitudeHemisphere>32</ns2:LongitudeHemisphere>" +
 		"					<ns2:Quality>1</ns2:Quality>" +
 		"					<ns2:SatInUse>8</ns2:SatInUse>" +
 		"					<ns2:Timestamp>2015-04-28T04:49:43</ns2:Timestamp>" +
 		"				</ns2:StartGPS>" +
 		"				<ns2:StartMileage>" + fmt.Sprint(t.OdoStart) + "</ns2:StartMileage>" +
-		"				<ns2:Stop>" + t.EndTime.In(loc).Format("2006-01-02T15:04:05") + "</ns2:Stop>" +
+		"				<ns2:Stop>" + v.FormatLocalTime(t.EndTime, loc) + "</ns2:Stop>" +
 		"				<ns2:StopGPS>" +
 		"					<ns2:Altitude>0.0</ns2:Altitude>" +
 		"					<ns2:Distance>0</ns2:Distance>" +
 		"					<ns2:Format>ddd_dddddd</ns2:Format>" +
-		"					<ns2:Latitude>51.49389166666666</ns2:Latitude>" +
+		"					<ns2:Latitude>" + fmt.Sprint(t.Vehicle.Latitude) + "</ns2:Latitude>" +
 		"					<ns2:LatitudeHemisphere>32</ns2:LatitudeHemisphere>" +
-		"					<ns2:Longitude>-0.107495</ns2:Longitude>" +
+		"					<ns2:Longitude>" + fmt.Sprint(t.Vehicle.Longitude) + "</ns2:Longitude>" +
 		"					<ns2:LongitudeHemisphere>32</ns2:LongitudeHemisphere>" +
 		"					<ns2:Quality>1</ns2:Quality>" +
 		"					<ns2:SatInUse>9</ns2:SatInUse>" +
@@ -436,14 +527,14 @@ func (t *Trip) GenerateTripData() string {
 		"				</ns2:StopGPS>" +
 		"				<ns2:StopMileage>" + fmt.Sprint(t.OdoEnd) + "</ns2:StopMileage>" +
 		"				<ns2:SystemTimestamp>" +
-		"					<ns3:Timezone>20</ns3:Timezone>" +
-		"					<ns3:UTCDateTime>" + time.Now().UTC().Format("2006-01-02T15:04:05.0000000Z") + "</ns3:UTCDateTime>" + //2015-05-01T07:20:20.2299095-05:00
+		"					<ns3:Timezone>" + fmt.Sprint(t.getTimezoneCode(e)) + "</ns3:Timezone>" +
+		"					<ns3:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05.0000000Z") + "</ns3:UTCDateTime>" + //2015-05-01T07:20:20.2299095-05:00
 		"				</ns2:SystemTimestamp>" +
 		"				<ns2:Tlv/>" +
-		"				<ns2:TripNo>1</ns2:TripNo>" +
+		"				<ns2:TripNo>" + fmt.Sprint(t.TripNo) + "</ns2:TripNo>" +
 		"				<ns2:Unused>" + didNotDrive + "</ns2:Unused>" +
 		"				<ns2:UserAccess>" +
-		"					<ns2:CardExtension>32</ns2:CardExtension>" +
+		"					<ns2:CardExtension>" + fmt.Sprint(e.Settings.Identifiers.CardExtension) + "</ns2:CardExtension>" +
 		"					<ns2:CardNo>" + t.AccessDevice.SmartcardCardNo + "</ns2:CardNo>" +
 		"					<ns2:CardOrga>" + t.AccessDevice.SmartcardOrgaNo + "</ns2:CardOrga>" +
 		"					<ns2:CocosSerialNo>0</ns2:CocosSerialNo>" +
@@ -454,7 +545,7 @@ func (t *Trip) GenerateTripData() string {
 		"					<ns2:TempPIN/>" +
 		"					<ns2:Type>" + t.AccessDevice.SmartcardType + "</ns2:Type>" +
 		"				</ns2:UserAccess>" +
-		"				<ns2:WithoutReservation>false</ns2:WithoutReservation>" +
+		"				<ns2:WithoutReservation>" + fmt.Sprint(t.WithoutReservation) + "</ns2:WithoutReservation>" +
 		"			</ns4:trip>" +
 		"		</ns4:RawTripEvaluated>" +
 		"	</soap:Body>" +
@@ -463,14 +554,69 @@ func (t *Trip) GenerateTripData() string {
 	return tripData
 }
 
-func (t *Trip) GenerateTripComplete() string {
-	return t.generateEvent(TRIP_COMPLETE)
+func (t *Trip) GenerateTripComplete(e *Environment, v *InterfaceVersion, now time.Time) string {
+	return t.generateEvent(e, v, now, TRIP_COMPLETE, false)
+}
+
+// generateDelayParameter reports how late a trip came back, it is empty for trips returned in time
+func (t *Trip) generateDelayParameter() string {
+	if !t.Late {
+		return ""
+	}
+
+	return "						<AdditionalParameter>" +
+		"							<Name>DelayMinutes</Name>" +
+		"							<ParamType>Int32</ParamType>" +
+		"							<Value>" + fmt.Sprint(t.DelayMinutes) + "</Value>" +
+		"						</AdditionalParameter>"
+}
+
+// getReservationNo returns the reservation number of the messages, 0 for trips without reservation
+func (t *Trip) getReservationNo() string {
+	if t.ReservationId == "" {
+		return "0"
+	}
+
+	return t.ReservationId
+}
+
+func (t *Trip) getTimezone(e *Environment) *time.Location {
+	if t.Reservation == nil {
+		return e.GetDefaultLocation()
+	}
+
+	return t.Reservation.GetTimezone(e)
+}
+
+func (t *Trip) getTimezoneCode(e *Environment) int {
+	if t.Reservation == nil {
+		return e.Settings.DefaultTimezone
+	}
+
+	return t.Reservation.GetTimezoneCode(e)
 }
 
-func (t *Trip) generateEvent(en EventName) string {
-	loc := t.Reservation.GetTimezone()
+func (t *Trip) generateEvent(e *Environment, v *InterfaceVersion, now time.Time, en EventName, openCmd bool) string {
+	loc := t.getTimezone(e)
 	var mil string
 
+	reason := "Card"
+	if openCmd {
+		reason = "Command"
+	}
+
+	pinTries := t.PINTries
+	if pinTries == 0 {
+		pinTries = 1
+	}
+
+	vehicleState := ""
+	if v.VehicleStateEvents {
+		vehicleState = "				<ns3:DoorOpen>" + fmt.Sprint(t.Vehicle.DoorOpen) + "</ns3:DoorOpen>" +
+			"				<ns3:Ignition>" + fmt.Sprint(t.Vehicle.IgnitionStatus) + "</ns3:Ignition>" +
+			"				<ns3:Immobilizer>" + fmt.Sprint(t.Vehicle.Immobilized) + "</ns3:Immobilizer>"
+	}
+
 	if en == TRIP_START || t.OdoEnd == 0 {
 		mil = fmt.Sprint(t.OdoStart)
 	} else {
@@ -479,30 +625,31 @@ func (t *Trip) generateEvent(en EventName) string {
 
 	return "<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"	<soap:Body>" +
-		"		<ns5:UsageEventReceived xmlns=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface\" xmlns:ns2=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface.EvMo\" xmlns:ns3=\"http://invers.com\" xmlns:ns4=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\" xmlns:ns5=\"http://tempuri.org/\" xmlns:ns6=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns7=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
+		"		<ns5:UsageEventReceived xmlns=\"" + v.IcsNamespace + "\" xmlns:ns2=\"" + v.IcsNamespace + ".EvMo\" xmlns:ns3=\"" + v.InversNamespace + "\" xmlns:ns4=\"" + v.DataTypesNamespace + "\" xmlns:ns5=\"http://tempuri.org/\" xmlns:ns6=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns7=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
 		"			<ns5:usage>" +
 		"				<ns2:AdditionalParameters>" +
 		"					<list>" +
 		"						<AdditionalParameter>" +
 		"							<Name>ReservationNo</Name>" +
 		"							<ParamType>UInt32</ParamType>" +
-		"							<Value>" + t.ReservationId + "</Value>" +
+		"							<Value>" + t.getReservationNo() + "</Value>" +
 		"						</AdditionalParameter>" +
+		t.generateDelayParameter() +
 		"					</list>" +
 		"				</ns2:AdditionalParameters>" +
 		"				<ns2:Description>" + fmt.Sprint(en) + "</ns2:Description>" +
-		"				<ns2:Id>27642813</ns2:Id>" +
+		"				<ns2:Id>" + e.Settings.Identifiers.EventId + "</ns2:Id>" +
 		"				<ns2:Position>" +
 		"					<ns3:Altitude>0.0</ns3:Altitude>" +
 		"					<ns3:Distance>0</ns3:Distance>" +
 		"					<ns3:Format>ddd_dddddd</ns3:Format>" +
-		"					<ns3:Latitude>51.49765166666667</ns3:Latitude>" +
+		"					<ns3:Latitude>" + fmt.Sprint(t.Vehicle.Latitude) + "</ns3:Latitude>" +
 		"					<ns3:LatitudeHemisphere>32</ns3:LatitudeHemisphere>" +
-		"					<ns3:Longitude>-0.217075</ns3:Longitude>" +
+		"					<ns3:Longitude>" + fmt.Sprint(t.Vehicle.Longitude) + "</ns3:Longitude>" +
 		"					<ns3:LongitudeHemisphere>32</ns3:LongitudeHemisphere>" +
 		"					<ns3:Quality>1</ns3:Quality>" +
 		"					<ns3:SatInUse>8</ns3:SatInUse>" +
-		"					<ns3:Timestamp>" + time.Now().In(loc).Format("2006-01-02T15:04:05") + "</ns3:Timestamp>" +
+		"					<ns3:Timestamp>" + v.FormatLocalTime(now, loc) + "</ns3:Timestamp>" +
 		"				</ns2:Position>" +
 		"				<ns2:SentStatus>Sending</ns2:SentStatus>" +
 		"				<ns2:Source>" +
@@ -543,26 +690,27 @@ func (t *Trip) generateEvent(en EventName) string {
 		"					<ns3:DestinationType>BCSA</ns3:DestinationType>" +
 		"					<ns3:Firmwareversion/>" +
 		"					<ns3:OrgaNo>" + t.VehicleDevice.OrgaNo + "</ns3:OrgaNo>" +
-		"					<ns3:SourceNo>132309508675338243</ns3:SourceNo>" +
+		"					<ns3:SourceNo>" + t.Vehicle.SourceNo + "</ns3:SourceNo>" +
 		"				</ns2:Source>" +
 		"				<ns2:SystemTimestamp xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:nil=\"true\"/>" +
-		"				<ns2:Timestamp>" + time.Now().In(loc).Format("2006-01-02T15:04:05") + "</ns2:Timestamp>" +
+		"				<ns2:Timestamp>" + v.FormatLocalTime(now, loc) + "</ns2:Timestamp>" +
 		"				<ns2:Tlv/>" +
 		"				<ns2:Type>12</ns2:Type>" +
 		"				<ns3:AnswerList/>" +
-		"				<ns3:BcStatus>WaitingForPIN</ns3:BcStatus>" +
+		"				<ns3:BcStatus>" + PIN_OK.BcStatus() + "</ns3:BcStatus>" +
 		"				<ns3:CallReason>Unknown</ns3:CallReason>" +
 		"				<ns3:CentralLockState>" +
-		"					<NewOpen>false</NewOpen>" +
-		"					<OpenCmd>false</OpenCmd>" +
-		"					<Reason>Card</Reason>" +
+		"					<NewOpen>" + fmt.Sprint(!t.Vehicle.Locked) + "</NewOpen>" +
+		"					<OpenCmd>" + fmt.Sprint(openCmd) + "</OpenCmd>" +
+		"					<Reason>" + reason + "</Reason>" +
 		"				</ns3:CentralLockState>" +
 		"				<ns3:DataFob>0</ns3:DataFob>" +
 		"				<ns3:Driver>false</ns3:Driver>" +
 		"				<ns3:DrivingDistance>0</ns3:DrivingDistance>" +
 		"				<ns3:EnterPassengerCount>0</ns3:EnterPassengerCount>" +
-		"				<ns3:Fuel>-1</ns3:Fuel>" +
+		"				<ns3:Fuel>" + fmt.Sprint(t.Vehicle.Fuel) + "</ns3:Fuel>" +
 		"				<ns3:FuelCard>0</ns3:FuelCard>" +
+		vehicleState +
 		"				<ns3:LedStatus>" +
 		"					<Green>false</Green>" +
 		"					<Red>false</Red>" +
@@ -572,9 +720,9 @@ func (t *Trip) generateEvent(en EventName) string {
 		"				<ns3:PassengerCount>0</ns3:PassengerCount>" +
 		"				<ns3:Pause>false</ns3:Pause>" +
 		"				<ns3:PinData>" +
-		"					<PINs>1</PINs>" +
+		"					<PINs>" + fmt.Sprint(pinTries) + "</PINs>" +
 		"					<Result>OK</Result>" +
-		"					<Tries>1</Tries>" +
+		"					<Tries>" + fmt.Sprint(pinTries) + "</Tries>" +
 		"				</ns3:PinData>" +
 		"				<ns3:ReservationItem>" +
 		"					<ID>0</ID>" +
@@ -591,7 +739,7 @@ func (t *Trip) generateEvent(en EventName) string {
 		"				<ns3:Start>1900-01-01T00:00:00</ns3:Start>" +
 		"				<ns3:Stop>1900-01-01T00:00:00</ns3:Stop>" +
 		"				<ns3:UserAccess>" +
-		"					<ns3:CardExtension>32</ns3:CardExtension>" +
+		"					<ns3:CardExtension>" + fmt.Sprint(e.Settings.Identifiers.CardExtension) + "</ns3:CardExtension>" +
 		"					<ns3:CardNo>" + t.AccessDevice.SmartcardCardNo + "</ns3:CardNo>" +
 		"					<ns3:CardOrga>" + t.AccessDevice.SmartcardOrgaNo + "</ns3:CardOrga>" +
 		"					<ns3:CocosSerialNo>0</ns3:CocosSerialNo>" +
@@ -608,7 +756,7 @@ func (t *Trip) generateEvent(en EventName) string {
 		"</soap:Envelope>"
 }
 
-func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
+func generateProblemEvent(e *Environment, v *InterfaceVersion, now time.Time, en EventName, t *Trip, ds *DriverSwipe) string {
 
 	var (
 		vehicleDevice     VehicleDevice
@@ -617,37 +765,53 @@ func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
 		smartcardCardNo   string
 		smartcardOrgaNo   string
 		reservationId     string
+		rejectionReason   RejectionReason
+		pinResult         PINResult
+		flags             Trip
+		pinTries          int
 		loc               *time.Location
+		vehicle           *Vehicle
 	)
 
+	rejectionReason = NO_REJECTION
+	pinResult = PIN_OK
+	pinTries = 1
+
 	if t != nil {
-		reservationId = t.ReservationId
+		reservationId = t.getReservationNo()
+		flags = Trip{Illegal: t.Illegal, EmergencyTrip: t.EmergencyTrip, EmergencyReason: t.EmergencyReason, WithoutReservation: t.WithoutReservation}
 		vehicleDevice = t.VehicleDevice
+		vehicle = t.Vehicle
 		smartcardType = t.AccessDevice.SmartcardType
 		smartcardSerialNo = t.AccessDevice.SmartcardSerialNo
 		smartcardCardNo = t.AccessDevice.SmartcardCardNo
 		smartcardOrgaNo = t.AccessDevice.SmartcardOrgaNo
-		loc = t.Reservation.GetTimezone()
+		loc = t.getTimezone(e)
 
 	} else if ds != nil {
 		reservationId = "0"
 		vehicleDevice = ds.VehicleDevice
+		vehicle = ds.Vehicle
 		smartcardType = ds.AccessDevice.SmartcardType
 		smartcardSerialNo = ds.AccessDevice.SmartcardSerialNo
 		smartcardCardNo = ds.AccessDevice.SmartcardCardNo
 		smartcardOrgaNo = ds.AccessDevice.SmartcardOrgaNo
-		loc, _ = time.LoadLocation("UTC")
-	}
+		loc = e.GetDefaultLocation()
 
-	if smartcardType == "Hitag16" {
-		smartcardType = "Hitag_16"
-	} else if smartcardType == "Hitag32" {
-		smartcardType = "Hitag_32"
+		if ds.RejectionReason != "" {
+			rejectionReason = ds.RejectionReason
+		}
+		if ds.PINResult != "" {
+			pinResult = ds.PINResult
+			pinTries = ds.PINTries
+		}
 	}
 
+	smartcardType = NormaliseCardType(smartcardType)
+
 	return "<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"	<soap:Body>" +
-		"		<ns5:UsageProblemEventReceived xmlns=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface\" xmlns:ns2=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface.EvMo\" xmlns:ns3=\"http://invers.com\" xmlns:ns4=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\" xmlns:ns5=\"http://tempuri.org/\" xmlns:ns6=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns7=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
+		"		<ns5:UsageProblemEventReceived xmlns=\"" + v.IcsNamespace + "\" xmlns:ns2=\"" + v.IcsNamespace + ".EvMo\" xmlns:ns3=\"" + v.InversNamespace + "\" xmlns:ns4=\"" + v.DataTypesNamespace + "\" xmlns:ns5=\"http://tempuri.org/\" xmlns:ns6=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns7=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
 		"			<ns5:usageProblem>" +
 		"				<ns2:AdditionalParameters>" +
 		"					<list>" +
@@ -659,18 +823,18 @@ func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
 		"					</list>" +
 		"				</ns2:AdditionalParameters>" +
 		"				<ns2:Description>" + fmt.Sprint(en) + "</ns2:Description>" +
-		"				<ns2:Id>27642813</ns2:Id>" +
+		"				<ns2:Id>" + e.Settings.Identifiers.EventId + "</ns2:Id>" +
 		"				<ns2:Position>" +
 		"					<ns3:Altitude>0.0</ns3:Altitude>" +
 		"					<ns3:Distance>0</ns3:Distance>" +
 		"					<ns3:Format>ddd_dddddd</ns3:Format>" +
-		"					<ns3:Latitude>51.49765166666667</ns3:Latitude>" +
+		"					<ns3:Latitude>" + fmt.Sprint(vehicle.Latitude) + "</ns3:Latitude>" +
 		"					<ns3:LatitudeHemisphere>32</ns3:LatitudeHemisphere>" +
-		"					<ns3:Longitude>-0.217075</ns3:Longitude>" +
+		"					<ns3:Longitude>" + fmt.Sprint(vehicle.Longitude) + "</ns3:Longitude>" +
 		"					<ns3:LongitudeHemisphere>32</ns3:LongitudeHemisphere>" +
 		"					<ns3:Quality>1</ns3:Quality>" +
 		"					<ns3:SatInUse>8</ns3:SatInUse>" +
-		"					<ns3:Timestamp>" + time.Now().In(loc).Format("2006-01-02T15:04:05") + "</ns3:Timestamp>" +
+		"					<ns3:Timestamp>" + v.FormatLocalTime(now, loc) + "</ns3:Timestamp>" +
 		"				</ns2:Position>" +
 		"				<ns2:SentStatus>Sending</ns2:SentStatus>" +
 		"				<ns2:Source>" +
@@ -711,14 +875,14 @@ func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
 		"					<ns3:DestinationType>BCSA</ns3:DestinationType>" +
 		"					<ns3:Firmwareversion/>" +
 		"					<ns3:OrgaNo>" + vehicleDevice.OrgaNo + "</ns3:OrgaNo>" +
-		"					<ns3:SourceNo>132309508675338243</ns3:SourceNo>" +
+		"					<ns3:SourceNo>" + vehicle.SourceNo + "</ns3:SourceNo>" +
 		"				</ns2:Source>" +
 		"				<ns2:SystemTimestamp xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:nil=\"true\"/>" +
-		"				<ns2:Timestamp>" + time.Now().In(loc).Format("2006-01-02T15:04:05") + "</ns2:Timestamp>" +
+		"				<ns2:Timestamp>" + v.FormatLocalTime(now, loc) + "</ns2:Timestamp>" +
 		"				<ns2:Tlv/>" +
 		"				<ns2:Type>12</ns2:Type>" +
 		"				<ns3:AnswerList/>" +
-		"				<ns3:BcStatus>WaitingForPIN</ns3:BcStatus>" +
+		"				<ns3:BcStatus>" + pinResult.BcStatus() + "</ns3:BcStatus>" +
 		"				<ns3:CallReason>Unknown</ns3:CallReason>" +
 		"				<ns3:CentralLockState>" +
 		"					<NewOpen>false</NewOpen>" +
@@ -728,21 +892,24 @@ func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
 		"				<ns3:DataFob>0</ns3:DataFob>" +
 		"				<ns3:Driver>false</ns3:Driver>" +
 		"				<ns3:DrivingDistance>0</ns3:DrivingDistance>" +
+		"				<ns3:EmergencyReason>" + flags.GetEmergencyReason() + "</ns3:EmergencyReason>" +
+		"				<ns3:EmergencyTrip>" + fmt.Sprint(flags.EmergencyTrip) + "</ns3:EmergencyTrip>" +
 		"				<ns3:EnterPassengerCount>0</ns3:EnterPassengerCount>" +
-		"				<ns3:Fuel>-1</ns3:Fuel>" +
+		"				<ns3:Fuel>" + fmt.Sprint(vehicle.Fuel) + "</ns3:Fuel>" +
 		"				<ns3:FuelCard>0</ns3:FuelCard>" +
+		"				<ns3:Illegal>" + fmt.Sprint(flags.Illegal) + "</ns3:Illegal>" +
 		"				<ns3:LedStatus>" +
 		"					<Green>false</Green>" +
 		"					<Red>false</Red>" +
 		"					<Yellow>false</Yellow>" +
 		"				</ns3:LedStatus>" +
-		"				<ns3:Mileage>0</ns3:Mileage>" +
+		"				<ns3:Mileage>" + fmt.Sprint(vehicle.Odometer) + "</ns3:Mileage>" +
 		"				<ns3:PassengerCount>0</ns3:PassengerCount>" +
 		"				<ns3:Pause>false</ns3:Pause>" +
 		"				<ns3:PinData>" +
-		"					<PINs>1</PINs>" +
-		"					<Result>OK</Result>" +
-		"					<Tries>1</Tries>" +
+		"					<PINs>" + fmt.Sprint(pinTries) + "</PINs>" +
+		"					<Result>" + fmt.Sprint(pinResult) + "</Result>" +
+		"					<Tries>" + fmt.Sprint(pinTries) + "</Tries>" +
 		"				</ns3:PinData>" +
 		"				<ns3:ReservationItem>" +
 		"					<ID>0</ID>" +
@@ -759,7 +926,7 @@ func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
 		"				<ns3:Start>1900-01-01T00:00:00</ns3:Start>" +
 		"				<ns3:Stop>1900-01-01T00:00:00</ns3:Stop>" +
 		"				<ns3:UserAccess>" +
-		"					<ns3:CardExtension>32</ns3:CardExtension>" +
+		"					<ns3:CardExtension>" + fmt.Sprint(e.Settings.Identifiers.CardExtension) + "</ns3:CardExtension>" +
 		"					<ns3:CardNo>" + smartcardCardNo + "</ns3:CardNo>" +
 		"					<ns3:CardOrga>" + smartcardOrgaNo + "</ns3:CardOrga>" +
 		"					<ns3:CocosSerialNo>0</ns3:CocosSerialNo>" +
@@ -770,14 +937,15 @@ func generateProblemEvent(en EventName, t *Trip, ds *DriverSwipe) string {
 		"					<ns3:TempPIN/>" +
 		"					<ns3:Type>" + smartcardType + "</ns3:Type>" +
 		"				</ns3:UserAccess>" +
-		"				<ns3:RejectedAccessReason>NoReservation</ns3:RejectedAccessReason>" +
+		"				<ns3:RejectedAccessReason>" + fmt.Sprint(rejectionReason) + "</ns3:RejectedAccessReason>" +
+		"				<ns3:WithoutReservation>" + fmt.Sprint(flags.WithoutReservation) + "</ns3:WithoutReservation>" +
 		"			</ns5:usageProblem>" +
 		"		</ns5:UsageProblemEventReceived>" +
 		"	</soap:Body>" +
 		"</soap:Envelope>"
 }
 
-func (ds *DriverSwipe) GenerateCUCMRequest() string {
+func (ds *DriverSwipe) GenerateCUCMRequest(e *Environment, v *InterfaceVersion, now time.Time) string {
 
 	var (
 		vehicleDevice     VehicleDevice
@@ -793,21 +961,17 @@ func (ds *DriverSwipe) GenerateCUCMRequest() string {
 	smartcardSerialNo = ds.AccessDevice.SmartcardSerialNo
 	smartcardCardNo = ds.AccessDevice.SmartcardCardNo
 	smartcardOrgaNo = ds.AccessDevice.SmartcardOrgaNo
-	loc, _ = time.LoadLocation("UTC")
+	loc = e.GetDefaultLocation()
 
-	if smartcardType == "Hitag16" {
-		smartcardType = "Hitag_16"
-	} else if smartcardType == "Hitag32" {
-		smartcardType = "Hitag_32"
-	}
+	smartcardType = NormaliseCardType(smartcardType)
 
 	return "<soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 		"	<soap:Body>" +
-		"		<ns5:RequestReceived xmlns=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface\" xmlns:ns2=\"http://schemas.datacontract.org/2004/07/Invers.Ics.Interface.EvMo\" xmlns:ns3=\"http://invers.com\" xmlns:ns4=\"http://schemas.datacontract.org/2004/07/Invers.DataTypes\" xmlns:ns5=\"http://tempuri.org/\" xmlns:ns6=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns7=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
+		"		<ns5:RequestReceived xmlns=\"" + v.IcsNamespace + "\" xmlns:ns2=\"" + v.IcsNamespace + ".EvMo\" xmlns:ns3=\"" + v.InversNamespace + "\" xmlns:ns4=\"" + v.DataTypesNamespace + "\" xmlns:ns5=\"http://tempuri.org/\" xmlns:ns6=\"http://schemas.microsoft.com/2003/10/Serialization/\" xmlns:ns7=\"http://schemas.datacontract.org/2004/07/System.Net.Mail\">" +
 		"			<ns5:request>" +
 		"				<CUCMNo>1</CUCMNo>" +
 		"				<CommSystem>GPRS</CommSystem>" +
-		"				<ID>40931</ID>" +
+		"				<ID>" + e.Settings.Identifiers.CUCMRequestId + "</ID>" +
 		"				<LoginName>" + vehicleDevice.VehiclePhoneNo + "</LoginName>" +
 		"				<SentStatus>Sending</SentStatus>" +
 		"				<Source>" +
@@ -848,19 +1012,19 @@ func (ds *DriverSwipe) GenerateCUCMRequest() string {
 		"					<ns3:DestinationType>BCSA</ns3:DestinationType>" +
 		"					<ns3:Firmwareversion/>" +
 		"					<ns3:OrgaNo>" + vehicleDevice.OrgaNo + "</ns3:OrgaNo>" +
-		"					<ns3:SourceNo>132309508675338243</ns3:SourceNo>" +
+		"					<ns3:SourceNo>" + ds.Vehicle.SourceNo + "</ns3:SourceNo>" +
 		"				</Source>" +
 		"				<SystemTimestamp xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\" xsi:nil=\"true\"/>" +
 		"				<Timestamp>" +
-		"					<ns3:Timezone>20</ns3:Timezone>" +
-		"					<ns3:UTCDateTime>" + time.Now().UTC().Format("2006-01-02T15:04:05Z") + "</ns3:UTCDateTime>" +
+		"					<ns3:Timezone>" + fmt.Sprint(e.Settings.DefaultTimezone) + "</ns3:Timezone>" +
+		"					<ns3:UTCDateTime>" + now.UTC().Format("2006-01-02T15:04:05Z") + "</ns3:UTCDateTime>" +
 		"				</Timestamp>" +
 		"				<Type>DemandReservation</Type>" +
 		"				<Waiting>false</Waiting>" +
 		"				<ns3:Request>" +
 		"					<ns3:Access>" +
 		"						<ns3:UserAccess>" +
-		"							<ns3:CardExtension>32</ns3:CardExtension>" +
+		"							<ns3:CardExtension>" + fmt.Sprint(e.Settings.Identifiers.CardExtension) + "</ns3:CardExtension>" +
 		"							<ns3:CardNo>" + smartcardCardNo + "</ns3:CardNo>" +
 		"							<ns3:CardOrga>" + smartcardOrgaNo + "</ns3:CardOrga>" +
 		"							<ns3:CocosSerialNo>0</ns3:CocosSerialNo>" +
@@ -884,7 +1048,7 @@ func (ds *DriverSwipe) GenerateCUCMRequest() string {
 		"					<ns3:Position/>" +
 		"					<ns3:RequestID>" + ds.CUCMGuid + "</ns3:RequestID>" +
 		"					<ns3:Type>ReservationCheck</ns3:Type>" +
-		"					<ns2:Timestamp>" + time.Now().In(loc).Format("2006-01-02T15:04:05") + "</ns2:Timestamp>" +
+		"					<ns2:Timestamp>" + v.FormatLocalTime(now, loc) + "</ns2:Timestamp>" +
 		"				</ns3:Request>" +
 		"			</ns5:request>" +
 		"		</ns5:RequestReceived>" +
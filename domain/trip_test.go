@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTripWithoutReservationMessages(t *testing.T) {
+	e := DefaultEnvironment(1)
+	v := GetInterfaceVersion(DEFAULT_INTERFACE_VERSION)
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	trip, err := NewTripWithoutReservation(EMERGENCY_TRIP, VehicleDevice{VehiclePhoneNo: "500", OrgaNo: "1"}, AccessDevice{SmartcardSerialNo: "1"}, "Accident")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trip.Vehicle = NewVehicle(e.Random, trip.VehicleDevice)
+
+	messages := map[string]string{
+		"trip segment":   trip.GenerateTripSegment(e, v, now),
+		"trip data":      trip.GenerateTripData(e, v, now),
+		"trip start":     trip.GenerateTripStart(e, v, now),
+		"emergency trip": trip.GenerateEmergencyTrip(e, v, now),
+	}
+
+	for name, value := range messages {
+		if strings.Contains(value, "<ns2:ReservationNo></ns2:ReservationNo>") || strings.Contains(value, "<Value></Value>") {
+			t.Errorf("%s without reservation number 0: %s", name, value)
+		}
+	}
+
+	if msg := messages["emergency trip"]; !strings.Contains(msg, "<ns3:RejectedAccessReason>"+string(NO_REJECTION)+"<") {
+		t.Errorf("emergency trip with a rejection reason: %s", msg)
+	}
+
+	ds := &DriverSwipe{VehicleDevice: trip.VehicleDevice, Vehicle: trip.Vehicle, RejectionReason: WRONG_PIN}
+	if msg := ds.GenerateRejectedAccess(e, v, now); !strings.Contains(msg, "<ns3:RejectedAccessReason>"+string(WRONG_PIN)+"<") {
+		t.Errorf("rejected access without its reason: %s", msg)
+	}
+}
+
+func TestMessagesWithoutReservationUseTheDefaultTimezone(t *testing.T) {
+	s := DefaultSettings()
+	s.Timezones[900] = "Europe/Paris"
+	s.DefaultTimezone = 900
+
+	e, err := NewEnvironment(s, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := GetInterfaceVersion(DEFAULT_INTERFACE_VERSION)
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	vd := VehicleDevice{VehiclePhoneNo: "500", OrgaNo: "1"}
+
+	trip, err := NewTripWithoutReservation(UNRESERVED_TRIP, vd, AccessDevice{SmartcardSerialNo: "1"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trip.Vehicle = NewVehicle(e.Random, vd)
+	ds := &DriverSwipe{VehicleDevice: vd, Vehicle: trip.Vehicle}
+
+	messages := map[string]string{
+		"trip start":      trip.GenerateTripStart(e, v, now),
+		"rejected access": ds.GenerateRejectedAccess(e, v, now),
+		"CUCM request":    ds.GenerateCUCMRequest(e, v, now),
+	}
+
+	for name, value := range messages {
+		if !strings.Contains(value, "Timestamp>2026-01-01T11:00:00<") {
+			t.Errorf("%s not in Paris time: %s", name, value)
+		}
+	}
+}
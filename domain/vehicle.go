@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+)
+
+type TripOptions struct {
+	SkipUnlockAtStart    bool
+	DoorLeftOpenAtReturn bool
+	SkipLockAtReturn     bool
+}
+
+type Vehicle struct {
+	VehicleDevice
+	SourceNo       string
+	Odometer       int
+	Fuel           int
+	Latitude       float64
+	Longitude      float64
+	IgnitionStatus bool
+	Locked         bool
+	Immobilized    bool
+	DoorOpen       bool
+	TripOptions    TripOptions
+
+	InterfaceVersion string
+}
+
+func NewVehicle(random *Random, vd VehicleDevice) *Vehicle {
+	v := new(Vehicle)
+
+	random = random.Derive("vehicle", vd.OrgaNo, vd.VehiclePhoneNo)
+
+	v.VehicleDevice = vd
+	v.SourceNo = fmt.Sprint(random.Int63())
+	v.Odometer = random.Int(100000)
+	v.Fuel = 100
+	v.Latitude = 51.493905
+	v.Longitude = -0.10749166666666667
+	v.Locked = true
+
+	return v
+}
+
+func (v *Vehicle) String() string {
+	string := ""
+
+	string += fmt.Sprint("Vehicle:") +
+		fmt.Sprint("\n\t") + fmt.Sprint("- VehiclePhoneNo: ") + fmt.Sprintf("%s", v.VehiclePhoneNo) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- OrgaNo: ") + fmt.Sprintf("%s", v.OrgaNo) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- SourceNo: ") + fmt.Sprintf("%s", v.SourceNo) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- Odometer: ") + fmt.Sprintf("%d", v.Odometer) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- Fuel: ") + fmt.Sprintf("%d", v.Fuel) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- Locked: ") + fmt.Sprintf("%t", v.Locked) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- Immobilized: ") + fmt.Sprintf("%t", v.Immobilized) +
+		fmt.Sprint("\n\t") + fmt.Sprint("- DoorOpen: ") + fmt.Sprintf("%t", v.DoorOpen)
+
+	return string
+}
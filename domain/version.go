@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+type InterfaceVersion struct {
+	Number             string
+	InversNamespace    string
+	IcsNamespace       string
+	DataTypesNamespace string
+	LocalTimeLayout    string
+	VehicleStateEvents bool
+}
+
+var interfaceVersions = map[string]*InterfaceVersion{
+	"21": &InterfaceVersion{
+		Number:             "21",
+		InversNamespace:    "http://invers.com",
+		IcsNamespace:       "http://schemas.datacontract.org/2004/07/Invers.Ics.Interface",
+		DataTypesNamespace: "http://schemas.datacontract.org/2004/07/Invers.DataTypes",
+		LocalTimeLayout:    "2006-01-02T15:04:05",
+		VehicleStateEvents: false,
+	},
+	//22 keeps the namespaces of 21, it differs by its path, the UTC offset of the local times and the
+	//vehicle state of the events
+	"22": &InterfaceVersion{
+		Number:             "22",
+		InversNamespace:    "http://invers.com",
+		IcsNamespace:       "http://schemas.datacontract.org/2004/07/Invers.Ics.Interface",
+		DataTypesNamespace: "http://schemas.datacontract.org/2004/07/Invers.DataTypes",
+		LocalTimeLayout:    "2006-01-02T15:04:05-07:00",
+		VehicleStateEvents: true,
+	},
+}
+
+func GetInterfaceVersion(number string) *InterfaceVersion {
+	return interfaceVersions[number]
+}
+
+func GetInterfaceVersions() []string {
+	versions := make([]string, 0)
+
+	for key := range interfaceVersions {
+		versions = append(versions, key)
+	}
+	sort.Strings(versions)
+
+	return versions
+}
+
+func (v *InterfaceVersion) FormatLocalTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(v.LocalTimeLayout)
+}
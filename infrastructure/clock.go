@@ -0,0 +1,23 @@
+package infrastructure
+
+import (
+	"time"
+)
+
+type SystemClock struct{}
+
+func NewSystemClock() *SystemClock {
+	return new(SystemClock)
+}
+
+func (c *SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *SystemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (c *SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,191 @@
+package infrastructure
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const SELF_SIGNED_VALIDITY = 365 * 24 * time.Hour
+
+// TLSOptions configure the HTTPS listener. A self-signed certificate is generated when asked for,
+// it is written to the certificate and key files when given and reused as long as they exist.
+type TLSOptions struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	SelfSigned   bool   `yaml:"selfSigned"`
+	Hosts        string `yaml:"hosts"` //comma separated host names and IPs of the self-signed certificate
+	ClientCAFile string `yaml:"clientCaFile"`
+}
+
+func (o *TLSOptions) Validate() error {
+	if !o.Enabled {
+		if o.ClientCAFile != "" {
+			return fmt.Errorf("Client certificate verification requires TLS")
+		}
+
+		return nil
+	}
+
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return fmt.Errorf("TLS certificate and key files go together")
+	}
+
+	if o.CertFile == "" && !o.SelfSigned {
+		return fmt.Errorf("TLS requires a certificate and key file or a self-signed certificate")
+	}
+
+	if o.SelfSigned && len(o.GetHosts()) == 0 {
+		return fmt.Errorf("Self-signed certificate requires at least one host")
+	}
+
+	return nil
+}
+
+func (o *TLSOptions) GetHosts() []string {
+	hosts := make([]string, 0)
+
+	for _, value := range strings.Split(o.Hosts, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			hosts = append(hosts, value)
+		}
+	}
+
+	return hosts
+}
+
+// NewTLSConfig loads or generates the server certificate, client certificates are verified against
+// the client CA when they are sent, the handlers decide which paths require one
+func NewTLSConfig(o TLSOptions) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	if o.SelfSigned && (o.CertFile == "" || !exists(o.CertFile) || !exists(o.KeyFile)) {
+		cert, err = generateCertificate(o)
+	} else {
+		cert, err = tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if o.ClientCAFile != "" {
+		b, err := ioutil.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("No certificate found in client CA file %s", o.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
+
+func generateCertificate(o TLSOptions) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Tako Tech Simulator"}, CommonName: o.GetHosts()[0]},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SELF_SIGNED_VALIDITY),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, value := range o.GetHosts() {
+		if ip := net.ParseIP(value); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, value)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	fmt.Printf("Self-signed certificate generated for %s, SHA-256 fingerprint %X\n", o.Hosts, sha256.Sum256(der))
+
+	//written out so Tako can be told to trust it, a restart reuses it
+	if o.CertFile != "" {
+		if err := ioutil.WriteFile(o.CertFile, certPEM, 0644); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := ioutil.WriteFile(o.KeyFile, keyPEM, 0600); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
+
+// NewSelfTrustConfig lets a client trust the certificate of the given server config, the sink
+// sends the outbound messages to the simulator itself
+func NewSelfTrustConfig(server *tls.Config) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+
+	for _, value := range server.Certificates[0].Certificate {
+		cert, err := x509.ParseCertificate(value)
+		if err != nil {
+			return nil, err
+		}
+
+		pool.AddCert(cert)
+	}
+
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
@@ -0,0 +1,62 @@
+package interfaces
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"net/http"
+)
+
+type CardServiceI interface {
+	GetCards() []*domain.Card
+	UpdateCard(c *domain.Card) (*domain.Card, error)
+	DeleteCard(a domain.AccessDevice) bool
+}
+
+type CardListener struct {
+	cardService CardServiceI
+}
+
+func NewCardListener(cs CardServiceI) *CardListener {
+	cl := new(CardListener)
+	cl.cardService = cs
+
+	return cl
+}
+
+func (cl *CardListener) Listen(mux *http.ServeMux) {
+	mux.HandleFunc("/cards", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, cl.cardService.GetCards())
+		case "PUT":
+			c := new(domain.Card)
+
+			if err := readJSON(r, c); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			if c, err := cl.cardService.UpdateCard(c); err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, c)
+			}
+		case "DELETE":
+			//the body identifies the card like a reservation access device
+			a := new(domain.AccessDevice)
+
+			if err := readJSON(r, a); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			if !cl.cardService.DeleteCard(*a) {
+				writeError(w, 404, fmt.Errorf("Card not found: %v", *a))
+			} else {
+				w.WriteHeader(200)
+			}
+		default:
+			w.WriteHeader(405)
+		}
+	})
+}
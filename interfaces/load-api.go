@@ -0,0 +1,90 @@
+package interfaces
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"net/http"
+	"time"
+)
+
+type LoadServiceI interface {
+	Start(o domain.LoadOptions) error
+	Stop()
+	SetRate(rate float64) error
+	GetStats() *domain.LoadStats
+}
+
+type LoadListener struct {
+	loadService LoadServiceI
+}
+
+type loadRequest struct {
+	OrgaNo       string
+	Vehicles     int
+	Rate         float64
+	TripDuration string
+	LateRatio    float64
+	NoShowRatio  float64
+}
+
+func NewLoadListener(ls LoadServiceI) *LoadListener {
+	ll := new(LoadListener)
+	ll.loadService = ls
+
+	return ll
+}
+
+func (ll *LoadListener) Listen(mux *http.ServeMux) {
+	mux.HandleFunc("/load", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, ll.loadService.GetStats())
+		case "POST":
+			//starts a load run: {"OrgaNo": "1", "Vehicles": 100, "Rate": 10, "TripDuration": "30m", "LateRatio": 0.1, "NoShowRatio": 0.1}
+			lr := new(loadRequest)
+
+			if err := readJSON(r, lr); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			d, err := time.ParseDuration(lr.TripDuration)
+
+			if err != nil {
+				writeError(w, 400, fmt.Errorf("Invalid trip duration: %v", err))
+				return
+			}
+
+			o := domain.LoadOptions{
+				OrgaNo:       lr.OrgaNo,
+				Vehicles:     lr.Vehicles,
+				Rate:         lr.Rate,
+				TripDuration: d,
+				LateRatio:    lr.LateRatio,
+				NoShowRatio:  lr.NoShowRatio,
+			}
+
+			if err = ll.loadService.Start(o); err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, ll.loadService.GetStats())
+			}
+		case "PUT":
+			//changes the throughput of the running load: {"Rate": 20}
+			lr := new(loadRequest)
+
+			if err := readJSON(r, lr); err != nil {
+				writeError(w, 400, err)
+			} else if err = ll.loadService.SetRate(lr.Rate); err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, ll.loadService.GetStats())
+			}
+		case "DELETE":
+			ll.loadService.Stop()
+			w.WriteHeader(204)
+		default:
+			w.WriteHeader(405)
+		}
+	})
+}
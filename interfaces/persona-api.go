@@ -0,0 +1,125 @@
+package interfaces
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"net/http"
+	"strings"
+)
+
+type PersonaServiceI interface {
+	GetPersonas() map[string]*domain.Persona
+	GetPersona(name string) *domain.Persona
+	UpdatePersona(p *domain.Persona) error
+	GetAssignments() domain.PersonaAssignments
+	Assign(kind string, key string, persona string) error
+	AssignCard(a domain.AccessDevice, persona string) error
+}
+
+type PersonaListener struct {
+	personaService PersonaServiceI
+}
+
+type personaAssignment struct {
+	Persona string
+	Card    *domain.AccessDevice `json:",omitempty"`
+}
+
+func NewPersonaListener(ps PersonaServiceI) *PersonaListener {
+	pl := new(PersonaListener)
+	pl.personaService = ps
+
+	return pl
+}
+
+func (pl *PersonaListener) Listen(mux *http.ServeMux) {
+	personas := func(w http.ResponseWriter, r *http.Request) {
+		//path is /personas/{name}
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/personas"), "/")
+
+		switch r.Method {
+		case "GET":
+			if name == "" {
+				writeJSON(w, pl.personaService.GetPersonas())
+			} else if p := pl.personaService.GetPersona(name); p != nil {
+				writeJSON(w, p)
+			} else {
+				w.WriteHeader(404)
+			}
+		case "PUT":
+			p := new(domain.Persona)
+
+			if err := readJSON(r, p); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			p.Name = name
+
+			if err := pl.personaService.UpdatePersona(p); err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, p)
+			}
+		default:
+			w.WriteHeader(405)
+		}
+	}
+
+	mux.HandleFunc("/personas", personas)
+	mux.HandleFunc("/personas/", personas)
+
+	mux.HandleFunc("/assignments", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+
+		writeJSON(w, pl.personaService.GetAssignments())
+	})
+
+	mux.HandleFunc("/assignments/", func(w http.ResponseWriter, r *http.Request) {
+		//path is /assignments/default, /assignments/reservation/{reservationId} or /assignments/card,
+		//the card is given in the body and matched like the swipes
+		keys := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, "/assignments"), "/"), "/", 2)
+		key := ""
+
+		if len(keys) == 2 {
+			key = keys[1]
+		} else if keys[0] != "default" && keys[0] != "card" {
+			writeError(w, 404, fmt.Errorf("Missing key for %s assignment", keys[0]))
+			return
+		}
+
+		pa := new(personaAssignment)
+
+		switch r.Method {
+		case "PUT", "DELETE":
+			if err := readJSON(r, pa); err != nil && (r.Method == "PUT" || keys[0] == "card") {
+				writeError(w, 400, err)
+				return
+			}
+		default:
+			w.WriteHeader(405)
+			return
+		}
+
+		var err error
+		if keys[0] == "card" && pa.Card == nil {
+			err = fmt.Errorf("Card assignments require a card")
+		} else if keys[0] == "card" {
+			if r.Method == "DELETE" {
+				pa.Persona = ""
+			}
+			err = pl.personaService.AssignCard(*pa.Card, pa.Persona)
+		} else {
+			err = pl.personaService.Assign(keys[0], key, pa.Persona)
+		}
+
+		if err != nil {
+			writeError(w, 400, err)
+		} else {
+			writeJSON(w, pa)
+		}
+	})
+}
@@ -1,7 +1,6 @@
 package interfaces
 
 import (
-	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -9,41 +8,61 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type ReservationServiceI interface {
-	HandleNewReservation(*domain.Reservation)
-	HandleNewDriverSwipe(ds *domain.DriverSwipe)
-	HandleNewCUCMResponse(cr *domain.CUCMResponse)
+	HandleNewReservation(*domain.Reservation) error
+	HandleNewDriverSwipe(ds *domain.DriverSwipe) error
+	HandleNewCUCMResponse(cr *domain.CUCMResponse) error
+	HandleNewCommand(c *domain.Command) error
 	GetReservations() []*domain.Reservation
 	GetReservation(id string) *domain.Reservation
+	GetCUCMRequests() domain.CUCMRequests
+	SetCUCMTimeout(d time.Duration) error
+	GetConflictOptions() domain.ConflictOptions
+	SetConflictOptions(o domain.ConflictOptions) error
+	GetLateOptions() domain.LateOptions
+	SetLateOptions(o domain.LateOptions) error
+}
+
+type lateOptions struct {
+	ReminderInterval string
+	MaxReminders     int
 }
 
 type ReservationListener struct {
 	reservationService ReservationServiceI
+	env                *domain.Environment
+	clock              domain.Clock
 }
 
 type ReservationClient struct {
-	takoEndpoint string
+	takoClient
 }
 
-func NewReservationClient(takoEndpoint string) *ReservationClient {
+func NewReservationClient(tenantService TenantServiceI, vehicleService VehicleServiceI, env *domain.Environment, clock domain.Clock) *ReservationClient {
 	rc := new(ReservationClient)
 
-	rc.takoEndpoint = takoEndpoint
+	rc.tenantService = tenantService
+	rc.vehicleService = vehicleService
+	rc.env = env
+	rc.clock = clock
 
 	return rc
 }
 
-func NewReservationListener(rs ReservationServiceI) *ReservationListener {
+func NewReservationListener(rs ReservationServiceI, env *domain.Environment, clock domain.Clock) *ReservationListener {
 	rl := new(ReservationListener)
 	rl.reservationService = rs
+	rl.env = env
+	rl.clock = clock
 
 	return rl
 }
 
-func (rl *ReservationListener) Listen() {
-	http.HandleFunc("/reservations/", func(w http.ResponseWriter, r *http.Request) {
+func (rl *ReservationListener) Listen(mux *http.ServeMux) {
+	mux.HandleFunc("/reservations/", func(w http.ResponseWriter, r *http.Request) {
 		var (
 			resp []byte
 			err  error
@@ -76,7 +95,89 @@ func (rl *ReservationListener) Listen() {
 		}
 	})
 
-	http.HandleFunc("/AuthService", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/cucm", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, rl.reservationService.GetCUCMRequests())
+		case "PUT":
+			//body is {"Timeout": "30s"}
+			crs := new(domain.CUCMRequests)
+
+			if err := readJSON(r, crs); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			d, err := time.ParseDuration(crs.Timeout)
+			if err == nil {
+				err = rl.reservationService.SetCUCMTimeout(d)
+			}
+
+			if err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, rl.reservationService.GetCUCMRequests())
+			}
+		default:
+			w.WriteHeader(405)
+		}
+	})
+
+	mux.HandleFunc("/conflicts", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, rl.reservationService.GetConflictOptions())
+		case "PUT":
+			o := rl.reservationService.GetConflictOptions()
+
+			if err := readJSON(r, &o); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			if err := rl.reservationService.SetConflictOptions(o); err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, o)
+			}
+		default:
+			w.WriteHeader(405)
+		}
+	})
+
+	mux.HandleFunc("/late", func(w http.ResponseWriter, r *http.Request) {
+		lo := rl.reservationService.GetLateOptions()
+
+		switch r.Method {
+		case "GET":
+		case "PUT":
+			//body is {"ReminderInterval": "15m", "MaxReminders": 3}
+			o := &lateOptions{ReminderInterval: lo.ReminderInterval.String(), MaxReminders: lo.MaxReminders}
+
+			if err := readJSON(r, o); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			d, err := time.ParseDuration(o.ReminderInterval)
+			if err == nil {
+				lo = domain.LateOptions{ReminderInterval: d, MaxReminders: o.MaxReminders}
+				err = rl.reservationService.SetLateOptions(lo)
+			}
+
+			if err != nil {
+				writeError(w, 400, err)
+				return
+			}
+		default:
+			w.WriteHeader(405)
+			return
+		}
+
+		writeJSON(w, &lateOptions{ReminderInterval: lo.ReminderInterval.String(), MaxReminders: lo.MaxReminders})
+	})
+
+	mux.HandleFunc("/AuthService", func(w http.ResponseWriter, r *http.Request) {
 
 		string := "<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\">" +
 			"<s:Body>" +
@@ -90,7 +191,7 @@ func (rl *ReservationListener) Listen() {
 		w.Write([]byte(string))
 	})
 
-	http.HandleFunc("/ComService", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/ComService", func(w http.ResponseWriter, r *http.Request) {
 		var (
 			b    []byte
 			resp []byte
@@ -106,6 +207,8 @@ func (rl *ReservationListener) Listen() {
 				resp, err = rl.listenForSwipe(b)
 			} else if strings.Contains(body, "AnswerRequest") {
 				resp, err = rl.listenForCUCMResponse(b)
+			} else if strings.Contains(body, "SendCommand") {
+				resp, err = rl.listenForCommand(b)
 			} else {
 				err = fmt.Errorf("Unsupported method")
 			}
@@ -126,13 +229,16 @@ func (rl *ReservationListener) listenForReservation(b []byte) ([]byte, error) {
 	rt := new(domain.Reservation)
 
 	if err := xml.Unmarshal(b, rt); err == nil {
-		rl.reservationService.HandleNewReservation(rt)
-		response := rt.GenerateResponse()
+		if err = rl.reservationService.HandleNewReservation(rt); err != nil {
+			return nil, err
+		}
+
+		response := rt.GenerateResponse(rl.env, rl.clock.Now())
 
 		return []byte(response), nil
 
 	} else {
-		return nil, fmt.Errorf("Error processing request:", err)
+		return nil, fmt.Errorf("Error processing request: %v", err)
 	}
 }
 
@@ -140,13 +246,16 @@ func (rl *ReservationListener) listenForSwipe(b []byte) ([]byte, error) {
 	ds := new(domain.DriverSwipe)
 
 	if err := xml.Unmarshal(b, ds); err == nil {
-		rl.reservationService.HandleNewDriverSwipe(ds)
-		response := ds.GenerateResponse()
+		if err = rl.reservationService.HandleNewDriverSwipe(ds); err != nil {
+			return nil, err
+		}
+
+		response := ds.GenerateResponse(rl.env, rl.clock.Now())
 
 		return []byte(response), nil
 
 	} else {
-		return nil, fmt.Errorf("Error processing request:", err)
+		return nil, fmt.Errorf("Error processing request: %v", err)
 	}
 }
 
@@ -154,32 +263,40 @@ func (rl *ReservationListener) listenForCUCMResponse(b []byte) ([]byte, error) {
 	cr := new(domain.CUCMResponse)
 
 	if err := xml.Unmarshal(b, cr); err == nil {
-		rl.reservationService.HandleNewCUCMResponse(cr)
-		response := cr.GenerateResponse()
+		if err = rl.reservationService.HandleNewCUCMResponse(cr); err != nil {
+			return nil, err
+		}
+
+		response := cr.GenerateResponse(rl.env, rl.clock.Now())
 
 		return []byte(response), nil
 
 	} else {
-		return nil, fmt.Errorf("Error processing request:", err)
+		return nil, fmt.Errorf("Error processing request: %v", err)
 	}
 }
 
-func (rc *ReservationClient) SendUpdate(r domain.RequestI) {
-	body := []byte(r.GenerateStatus())
-	req, err := http.NewRequest("POST", rc.takoEndpoint+"/ws/invers/21/"+r.GetOrgaNo()+"/com", bytes.NewBuffer(body))
+func (rl *ReservationListener) listenForCommand(b []byte) ([]byte, error) {
+	c := new(domain.Command)
 
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	if err := xml.Unmarshal(b, c); err == nil {
+		if !c.Type.IsValid() {
+			return nil, fmt.Errorf("Unsupported command: %s", c.Type)
+		}
 
-		if err == nil {
-			fmt.Println("status update sent:", r.GetTechStatus())
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
+		if err = rl.reservationService.HandleNewCommand(c); err != nil {
+			return nil, err
 		}
-	}
 
-	if err != nil {
-		fmt.Println("Status update error:", err)
+		response := c.GenerateResponse(rl.env, rl.clock.Now())
+
+		return []byte(response), nil
+
+	} else {
+		return nil, fmt.Errorf("Error processing request: %v", err)
 	}
 }
+
+func (rc *ReservationClient) SendUpdate(r domain.RequestI) {
+	rc.send(r.GetOrgaNo(), rc.getVehicle(r.GetVehicleDevice()), "com", r.GenerateStatus, "status update "+fmt.Sprint(r.GetTechStatus()))
+}
@@ -0,0 +1,208 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type SinkServiceI interface {
+	HandleMessage(m *domain.ReceivedMessage) *domain.SinkResponse
+	HandleCUCMRequest(cr *domain.CUCMRequest)
+	GetMessages(f *domain.MessageFilter) []*domain.ReceivedMessage
+	ClearMessages()
+	GetResponses() map[string]*domain.SinkResponse
+	SetResponse(path string, r *domain.SinkResponse)
+	GetCUCMAnswer() domain.CUCMAnswer
+	SetCUCMAnswer(a domain.CUCMAnswer)
+}
+
+type SinkListener struct {
+	sinkService SinkServiceI
+}
+
+type cucmAnswerConfig struct {
+	Answer domain.CUCMAnswer
+}
+
+var sinkPaths = map[string]bool{"event": true, "trip": true, "com": true, "res": true}
+
+func NewSinkListener(ss SinkServiceI) *SinkListener {
+	sl := new(SinkListener)
+	sl.sinkService = ss
+
+	return sl
+}
+
+func (sl *SinkListener) Listen(mux *http.ServeMux) {
+	mux.HandleFunc("/ws/invers/", sl.receive)
+
+	mux.HandleFunc("/sink/messages", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, sl.sinkService.GetMessages(getMessageFilter(r)))
+		case "DELETE":
+			sl.sinkService.ClearMessages()
+			w.WriteHeader(204)
+		default:
+			w.WriteHeader(405)
+		}
+	})
+
+	mux.HandleFunc("/sink/assert", sl.assert)
+
+	responses := func(w http.ResponseWriter, r *http.Request) {
+		//path is /sink/responses/{path}
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/sink/responses"), "/")
+
+		switch r.Method {
+		case "GET":
+			writeJSON(w, sl.sinkService.GetResponses())
+		case "PUT":
+			sr := domain.NewSinkResponse()
+
+			if !sinkPaths[path] {
+				writeError(w, 404, fmt.Errorf("Unknown sink path: %s", path))
+			} else if err := readJSON(r, sr); err != nil {
+				writeError(w, 400, err)
+			} else {
+				sl.sinkService.SetResponse(path, sr)
+				writeJSON(w, sr)
+			}
+		case "DELETE":
+			sl.sinkService.SetResponse(path, nil)
+			w.WriteHeader(204)
+		default:
+			w.WriteHeader(405)
+		}
+	}
+
+	mux.HandleFunc("/sink/responses", responses)
+	mux.HandleFunc("/sink/responses/", responses)
+
+	mux.HandleFunc("/sink/cucm", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, &cucmAnswerConfig{Answer: sl.sinkService.GetCUCMAnswer()})
+		case "PUT":
+			c := new(cucmAnswerConfig)
+
+			if err := readJSON(r, c); err != nil {
+				writeError(w, 400, err)
+			} else if !c.Answer.IsValid() {
+				writeError(w, 400, fmt.Errorf("Unsupported CUCM answer: %s", c.Answer))
+			} else {
+				sl.sinkService.SetCUCMAnswer(c.Answer)
+				writeJSON(w, c)
+			}
+		default:
+			w.WriteHeader(405)
+		}
+	})
+}
+
+func (sl *SinkListener) receive(w http.ResponseWriter, r *http.Request) {
+	//path is /ws/invers/{version}/{orgaNo}/{event|trip|com|res}
+	keys := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ws/invers/"), "/"), "/")
+
+	if len(keys) != 3 || !sinkPaths[keys[2]] {
+		w.WriteHeader(404)
+		return
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		writeError(w, 500, err)
+		return
+	}
+
+	m := new(domain.ReceivedMessage)
+	m.InterfaceVersion = keys[0]
+	m.OrgaNo = keys[1]
+	m.Path = keys[2]
+	m.Body = string(b)
+
+	sr := sl.sinkService.HandleMessage(m)
+
+	if m.Path == "res" && strings.Contains(m.Body, "RequestReceived") {
+		cr := new(domain.CUCMRequest)
+
+		if err = xml.Unmarshal(b, cr); err != nil {
+			fmt.Println("ERROR: Error processing CUCM request:", err)
+		} else {
+			sl.sinkService.HandleCUCMRequest(cr)
+		}
+	}
+
+	w.WriteHeader(sr.Status)
+	w.Write([]byte(sr.Body))
+}
+
+// assert answers 200 with the matching messages when the expectation holds and 417 otherwise,
+// without count at least one message has to match
+func (sl *SinkListener) assert(w http.ResponseWriter, r *http.Request) {
+	messages := sl.sinkService.GetMessages(getMessageFilter(r))
+
+	if value := r.URL.Query().Get("count"); value != "" {
+		count, err := strconv.Atoi(value)
+
+		if err != nil {
+			writeError(w, 400, fmt.Errorf("Invalid count: %s", value))
+			return
+		}
+
+		if len(messages) != count {
+			writeError(w, 417, fmt.Errorf("Expected %d matching messages, received %d", count, len(messages)))
+			return
+		}
+	} else if len(messages) == 0 {
+		writeError(w, 417, fmt.Errorf("Expected matching messages, received none"))
+		return
+	}
+
+	writeJSON(w, messages)
+}
+
+func getMessageFilter(r *http.Request) *domain.MessageFilter {
+	q := r.URL.Query()
+
+	return &domain.MessageFilter{
+		OrgaNo:   q.Get("orga"),
+		Path:     q.Get("path"),
+		Contains: q.Get("contains"),
+	}
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	b, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+
+	if err != nil {
+		writeError(w, 500, err)
+		return
+	}
+
+	w.WriteHeader(200)
+	w.Write(b)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	fmt.Println("ERROR:", err)
+	w.WriteHeader(status)
+	w.Write([]byte(err.Error()))
+}
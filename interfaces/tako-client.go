@@ -0,0 +1,152 @@
+package interfaces
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const DEFAULT_SEND_TIMEOUT = 30 * time.Second
+
+type TenantServiceI interface {
+	GetTenant(orgaNo string) *domain.Tenant
+}
+
+type SentMessage struct {
+	Name     string
+	OrgaNo   string
+	URL      string
+	Body     string
+	Status   int
+	Error    string
+	Time     time.Time
+	Duration time.Duration
+}
+
+type generator func(*domain.Environment, *domain.InterfaceVersion, time.Time) string
+
+type takoClient struct {
+	tenantService  TenantServiceI
+	vehicleService VehicleServiceI
+	env            *domain.Environment
+	clock          domain.Clock
+	onSend         []func(*SentMessage)
+	transport      http.RoundTripper
+	timeout        time.Duration
+
+	mutex sync.Mutex
+	ctx   context.Context
+}
+
+func (c *takoClient) OnSend(f func(*SentMessage)) {
+	c.onSend = append(c.onSend, f)
+}
+
+// getVehicle looks the vehicle of a message up, it may run another interface version than its tenant
+func (c *takoClient) getVehicle(vd domain.VehicleDevice) *domain.Vehicle {
+	if c.vehicleService == nil {
+		return nil
+	}
+
+	return c.vehicleService.GetVehicle(vd.OrgaNo, vd.VehiclePhoneNo)
+}
+
+// SetTimeout limits the time of one request to Tako, DEFAULT_SEND_TIMEOUT when not set
+func (c *takoClient) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// SetContext bounds the requests still sent, the simulator passes the shutdown deadline
+func (c *takoClient) SetContext(ctx context.Context) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.ctx = ctx
+}
+
+func (c *takoClient) getContext() context.Context {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
+
+// SetTLSConfig replaces the default TLS settings of the HTTPS requests
+func (c *takoClient) SetTLSConfig(config *tls.Config) {
+	c.transport = &http.Transport{TLSClientConfig: config}
+}
+
+func (c *takoClient) post(orgaNo string, vehicle *domain.Vehicle, path string, generate generator, msg *SentMessage) (*http.Response, error) {
+	tenant := c.tenantService.GetTenant(orgaNo)
+
+	if tenant == nil {
+		return nil, fmt.Errorf("No tenant configured for orga %s", orgaNo)
+	}
+
+	//a vehicle may run another interface version than the rest of its tenant
+	number := tenant.InterfaceVersion
+	if vehicle != nil && vehicle.InterfaceVersion != "" {
+		number = vehicle.InterfaceVersion
+	}
+
+	v := domain.GetInterfaceVersion(number)
+
+	if v == nil {
+		return nil, fmt.Errorf("Unsupported interface version %s", number)
+	}
+
+	msg.URL = tenant.GetEndpoint(v, path)
+	msg.Body = generate(c.env, v, msg.Time)
+
+	req, err := http.NewRequestWithContext(c.getContext(), "POST", msg.URL, bytes.NewBufferString(msg.Body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tenant.Username != "" {
+		req.SetBasicAuth(tenant.Username, tenant.Password)
+	}
+
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_SEND_TIMEOUT
+	}
+
+	client := &http.Client{Transport: c.transport, Timeout: timeout}
+	return client.Do(req)
+}
+
+func (c *takoClient) send(orgaNo string, vehicle *domain.Vehicle, path string, generate generator, name string) {
+	msg := new(SentMessage)
+	msg.Name = name
+	msg.OrgaNo = orgaNo
+	msg.Time = c.clock.Now()
+
+	start := time.Now()
+	resp, err := c.post(orgaNo, vehicle, path, generate, msg)
+	msg.Duration = time.Since(start)
+
+	if err == nil {
+		fmt.Println(name, "sent")
+		fmt.Println("response Status:", resp.Status)
+		msg.Status = resp.StatusCode
+		resp.Body.Close()
+	} else {
+		fmt.Println(name, "error:", err)
+		msg.Error = err.Error()
+	}
+
+	for _, f := range c.onSend {
+		f(msg)
+	}
+}
@@ -0,0 +1,46 @@
+package interfaces
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"net/http"
+	"strings"
+)
+
+type TaskServiceI interface {
+	GetTasks() []*domain.Task
+	GetTask(taskNumber string) *domain.Task
+}
+
+type TaskListener struct {
+	taskService TaskServiceI
+}
+
+func NewTaskListener(ts TaskServiceI) *TaskListener {
+	tl := new(TaskListener)
+	tl.taskService = ts
+
+	return tl
+}
+
+func (tl *TaskListener) Listen(mux *http.ServeMux) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.WriteHeader(405)
+			return
+		}
+
+		//path is /tasks/{taskNumber}
+		taskNumber := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks"), "/")
+
+		if taskNumber == "" {
+			writeJSON(w, tl.taskService.GetTasks())
+		} else if t := tl.taskService.GetTask(taskNumber); t != nil {
+			writeJSON(w, t)
+		} else {
+			w.WriteHeader(404)
+		}
+	}
+
+	mux.HandleFunc("/tasks", handler)
+	mux.HandleFunc("/tasks/", handler)
+}
@@ -0,0 +1,24 @@
+package interfaces
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RequireClientCertificate refuses the requests to the given paths without a verified client certificate,
+// the other paths stay open to clients without certificate
+func RequireClientCertificate(next http.Handler, paths ...string) http.Handler {
+	protected := make(map[string]bool)
+	for _, value := range paths {
+		protected[value] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if protected[r.URL.Path] && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("Client certificate required for %s from %s", r.URL.Path, r.RemoteAddr))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,220 +1,143 @@
 package interfaces
 
 import (
-	"bytes"
-	"fmt"
 	"github.com/leoride/tako-sim/domain"
 	"net/http"
+	"time"
 )
 
-type TripClient struct {
-	takoEndpoint string
+type TripServiceI interface {
+	GetTrips() []*domain.Trip
+	HandleTripWithoutReservation(kind domain.TripKind, vd domain.VehicleDevice, a domain.AccessDevice, emergencyReason string, d time.Duration) (*domain.Trip, error)
 }
 
-func NewTripClient(takoEndpoint string) *TripClient {
-	tc := new(TripClient)
+type TripListener struct {
+	tripService TripServiceI
+}
 
-	tc.takoEndpoint = takoEndpoint
+type TripClient struct {
+	takoClient
+}
 
-	return tc
+type tripRequest struct {
+	Kind            domain.TripKind
+	VehicleDevice   domain.VehicleDevice
+	AccessDevice    domain.AccessDevice
+	EmergencyReason string
+	Duration        string
 }
 
-func (tc *TripClient) SendTripStart(t *domain.Trip) {
-	body := []byte(t.GenerateTripStart())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
+func NewTripListener(ts TripServiceI) *TripListener {
+	tl := new(TripListener)
+	tl.tripService = ts
 
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	return tl
+}
 
-		if err == nil {
-			fmt.Println("trip start sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
+func (tl *TripListener) Listen(mux *http.ServeMux) {
+	mux.HandleFunc("/trips", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, tl.tripService.GetTrips())
+		case "POST":
+			//body is {"Kind": "illegal|emergency|unreserved", "VehicleDevice": {...}, "AccessDevice": {...}, "EmergencyReason": "...", "Duration": "20m"}
+			tr := &tripRequest{Duration: "15m"}
+
+			if err := readJSON(r, tr); err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			d, err := time.ParseDuration(tr.Duration)
+			if err != nil {
+				writeError(w, 400, err)
+				return
+			}
+
+			if t, err := tl.tripService.HandleTripWithoutReservation(tr.Kind, tr.VehicleDevice, tr.AccessDevice, tr.EmergencyReason, d); err != nil {
+				writeError(w, 400, err)
+			} else {
+				writeJSON(w, t)
+			}
+		default:
+			w.WriteHeader(405)
 		}
-	}
-
-	if err != nil {
-		fmt.Println("Trip start error:", err)
-	}
+	})
 }
 
-func (tc *TripClient) SendFirstIgnition(t *domain.Trip) {
-	body := []byte(t.GenerateFirstIgnition())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
+func NewTripClient(tenantService TenantServiceI, vehicleService VehicleServiceI, env *domain.Environment, clock domain.Clock) *TripClient {
+	tc := new(TripClient)
 
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	tc.tenantService = tenantService
+	tc.vehicleService = vehicleService
+	tc.env = env
+	tc.clock = clock
 
-		if err == nil {
-			fmt.Println("first ignition sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
+	return tc
+}
 
-	if err != nil {
-		fmt.Println("first ignition error:", err)
-	}
+func (tc *TripClient) SendTripStart(t *domain.Trip) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateTripStart, "trip start")
 }
 
-func (tc *TripClient) SendDataFobAction(t *domain.Trip, removed bool) {
-	body := []byte(t.GenerateDataFobAction(removed))
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
+func (tc *TripClient) SendFirstIgnition(t *domain.Trip) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateFirstIgnition, "first ignition")
+}
 
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
+func (tc *TripClient) SendDataFobAction(t *domain.Trip, removed bool) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", func(e *domain.Environment, v *domain.InterfaceVersion, now time.Time) string {
+		return t.GenerateDataFobAction(e, v, now, removed)
+	}, "datafob event")
+}
 
-		if err == nil {
-			fmt.Println("datafob event sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
+func (tc *TripClient) SendLockAction(t *domain.Trip, locked bool) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", func(e *domain.Environment, v *domain.InterfaceVersion, now time.Time) string {
+		return t.GenerateLockAction(e, v, now, locked)
+	}, "central lock event")
+}
 
-	if err != nil {
-		fmt.Println("datafob event error:", err)
-	}
+func (tc *TripClient) SendDoorAction(t *domain.Trip, open bool) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", func(e *domain.Environment, v *domain.InterfaceVersion, now time.Time) string {
+		return t.GenerateDoorAction(e, v, now, open)
+	}, "door event")
 }
 
 func (tc *TripClient) SendTripEnd(t *domain.Trip) {
-	body := []byte(t.GenerateTripEnd())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			fmt.Println("trip end sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
-
-	if err != nil {
-		fmt.Println("Trip end error:", err)
-	}
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateTripEnd, "trip end")
 }
 
 func (tc *TripClient) SendTripSegment(t *domain.Trip) {
-	body := []byte(t.GenerateTripSegment())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/trip", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			fmt.Println("trip segment sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
-
-	if err != nil {
-		fmt.Println("Trip segment error:", err)
-	}
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "trip", t.GenerateTripSegment, "trip segment")
 }
 
 func (tc *TripClient) SendTripData(t *domain.Trip) {
-	body := []byte(t.GenerateTripData())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/trip", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			fmt.Println("trip data sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
-
-	if err != nil {
-		fmt.Println("Trip data error:", err)
-	}
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "trip", t.GenerateTripData, "trip data")
 }
 
 func (tc *TripClient) SendTripComplete(t *domain.Trip) {
-	body := []byte(t.GenerateTripComplete())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			fmt.Println("trip complete sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
-
-	if err != nil {
-		fmt.Println("Trip complete error:", err)
-	}
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateTripComplete, "trip complete")
 }
 
 func (tc *TripClient) SendDriverLate(t *domain.Trip) {
-	body := []byte(t.GenerateDriverLate())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+t.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateDriverLate, "driver late")
+}
 
-		if err == nil {
-			fmt.Println("driver late sent:", fmt.Sprint(t.Status))
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
+func (tc *TripClient) SendIllegalTrip(t *domain.Trip) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateIllegalTrip, "illegal trip")
+}
 
-	if err != nil {
-		fmt.Println("Driver late error:", err)
-	}
+func (tc *TripClient) SendEmergencyTrip(t *domain.Trip) {
+	tc.send(t.VehicleDevice.OrgaNo, t.Vehicle, "event", t.GenerateEmergencyTrip, "emergency trip")
 }
 
 func (tc *TripClient) SendRejectedAccess(ds *domain.DriverSwipe) {
-	body := []byte(ds.GenerateRejectedAccess())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+ds.VehicleDevice.OrgaNo+"/event", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			fmt.Println("rejected access sent")
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
-
-	if err != nil {
-		fmt.Println("Rejected access error:", err)
-	}
+	tc.send(ds.VehicleDevice.OrgaNo, tc.getVehicle(ds.VehicleDevice), "event", ds.GenerateRejectedAccess, "rejected access")
 }
 
 func (tc *TripClient) SendCUCMRequest(ds *domain.DriverSwipe) {
-	body := []byte(ds.GenerateCUCMRequest())
-	req, err := http.NewRequest("POST", tc.takoEndpoint+"/ws/invers/21/"+ds.VehicleDevice.OrgaNo+"/res", bytes.NewBuffer(body))
-
-	if err == nil {
-		client := &http.Client{}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			fmt.Println("CUCM request sent")
-			fmt.Println("response Status:", resp.Status)
-			defer resp.Body.Close()
-		}
-	}
+	tc.send(ds.VehicleDevice.OrgaNo, tc.getVehicle(ds.VehicleDevice), "res", ds.GenerateCUCMRequest, "CUCM request")
+}
 
-	if err != nil {
-		fmt.Println("CUCM request error:", err)
-	}
+func (tc *TripClient) SendCommandEvent(c *domain.Command) {
+	tc.send(c.VehicleDevice.OrgaNo, c.Vehicle, "event", c.GenerateCommandEvent, "command event")
 }
@@ -0,0 +1,117 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+type VehicleServiceI interface {
+	GetVehicles() []*domain.Vehicle
+	GetVehicle(orgaNo string, phoneNo string) *domain.Vehicle
+	UpdateVehicle(v *domain.Vehicle) *domain.Vehicle
+}
+
+type VehicleListener struct {
+	vehicleService VehicleServiceI
+}
+
+func NewVehicleListener(vs VehicleServiceI) *VehicleListener {
+	vl := new(VehicleListener)
+	vl.vehicleService = vs
+
+	return vl
+}
+
+func (vl *VehicleListener) Listen(mux *http.ServeMux) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var (
+			resp []byte
+			err  error
+		)
+
+		//path is /vehicles/{orgaNo}/{vehiclePhoneNo}
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/vehicles"), "/")
+		keys := strings.SplitN(id, "/", 2)
+
+		switch r.Method {
+		case "GET":
+			if id == "" {
+				//return all
+				resp, err = json.Marshal(vl.vehicleService.GetVehicles())
+			} else if len(keys) == 2 {
+				//return one
+				v := vl.vehicleService.GetVehicle(keys[0], keys[1])
+
+				if v != nil {
+					resp, err = json.Marshal(v)
+				} else {
+					w.WriteHeader(404)
+					return
+				}
+			} else {
+				w.WriteHeader(404)
+				return
+			}
+		case "PUT":
+			resp, err = vl.updateVehicle(r, keys)
+		default:
+			w.WriteHeader(405)
+			return
+		}
+
+		if err != nil {
+			fmt.Println("ERROR:", err)
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+		} else {
+			w.WriteHeader(200)
+			w.Write(resp)
+		}
+	}
+
+	mux.HandleFunc("/vehicles", handler)
+	mux.HandleFunc("/vehicles/", handler)
+}
+
+func (vl *VehicleListener) updateVehicle(r *http.Request, keys []string) ([]byte, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if b, err = ioutil.ReadAll(r.Body); err != nil {
+		return nil, err
+	}
+
+	v := new(domain.Vehicle)
+
+	if len(keys) == 2 {
+		//start from the stored state so that partial updates keep the other fields
+		if existing := vl.vehicleService.GetVehicle(keys[0], keys[1]); existing != nil {
+			*v = *existing
+		}
+	}
+
+	if err = json.Unmarshal(b, v); err != nil {
+		return nil, fmt.Errorf("Error processing vehicle: %v", err)
+	}
+
+	if len(keys) == 2 {
+		v.OrgaNo = keys[0]
+		v.VehiclePhoneNo = keys[1]
+	}
+
+	if v.OrgaNo == "" || v.VehiclePhoneNo == "" {
+		return nil, fmt.Errorf("OrgaNo and VehiclePhoneNo are required")
+	}
+
+	if v.InterfaceVersion != "" && domain.GetInterfaceVersion(v.InterfaceVersion) == nil {
+		return nil, fmt.Errorf("Unsupported interface version: %s, supported versions are %v", v.InterfaceVersion, domain.GetInterfaceVersions())
+	}
+
+	return json.Marshal(vl.vehicleService.UpdateVehicle(v))
+}
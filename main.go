@@ -1,45 +1,285 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"github.com/leoride/tako-sim/domain"
+	"github.com/leoride/tako-sim/infrastructure"
 	"github.com/leoride/tako-sim/interfaces"
-	"github.com/leoride/tako-sim/usecases"
+	"github.com/leoride/tako-sim/simulator"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	fmt.Println("===== STARTING TAKO TECH SIMULATOR =====")
 
 	var (
-		takoEndpoint string
-		port         int
+		configFile string
 
-		tc *interfaces.TripClient
-		ts *usecases.TripService
+		config = NewConfig()
+		sim    *simulator.Simulator
+		state  *domain.State
 
-		rc *interfaces.ReservationClient
-		rs *usecases.ReservationService
-		rl *interfaces.ReservationListener
+		serverTLS *tls.Config
+		clientTLS *tls.Config
 
-		reservations []*domain.Reservation = make([]*domain.Reservation, 0)
-		trips        []*domain.Trip        = make([]*domain.Trip, 0)
+		tenants []*domain.Tenant = make([]*domain.Tenant, 0)
+		cards   []*domain.Card   = make([]*domain.Card, 0)
 	)
 
-	flag.StringVar(&takoEndpoint, "takoEndpoint", "http://localhost:8080/tako-fc", "Tako FC root URL")
-	flag.IntVar(&port, "port", 8282, "Port the app listens to")
+	flag.StringVar(&configFile, "config", "", "YAML config file, overridden by TAKOSIM_* environment variables and by the flags")
+	flag.StringVar(&config.TakoEndpoint, "takoEndpoint", config.TakoEndpoint, "Tako FC root URL")
+	flag.StringVar(&config.InterfaceVersion, "interfaceVersion", config.InterfaceVersion, "Invers interface version used when no tenant configuration is given")
+	flag.StringVar(&config.TenantsFile, "tenants", "", "JSON file with the per-orga Tako tenant configuration")
+	flag.StringVar(&config.CardsFile, "cards", "", "JSON file with the registered smartcards and their access-control rules")
+	flag.IntVar(&config.Port, "port", config.Port, "Port the app listens to")
+	flag.StringVar(&config.TimezonesFile, "timezones", "", "JSON file mapping Invers timezone codes to IANA timezones")
+	flag.BoolVar(&config.Sink, "sink", false, "Receive outbound messages locally instead of sending them to Tako FC")
+	flag.StringVar((*string)(&config.SinkCUCM), "sinkCucm", "", "Let the sink answer CUCM requests automatically: accept or reject")
+	flag.IntVar(&config.SinkMaxMessages, "sinkMaxMessages", config.SinkMaxMessages, "Received messages the sink keeps, the oldest ones are dropped beyond it")
+	flag.StringVar(&config.Persona, "persona", "", "Driver persona playing every reservation without an own assignment, e.g. punctual, late or noShow")
+	flag.DurationVar(&config.CUCMTimeout, "cucmTimeout", config.CUCMTimeout, "Time Tako has to answer a CUCM request before the access is rejected")
+	flag.DurationVar(&config.SendTimeout, "sendTimeout", config.SendTimeout, "Time a request to Tako may take before it is given up")
+	flag.BoolVar(&config.Conflicts.RejectOverlaps, "rejectOverlaps", false, "Refuse reservations overlapping another reservation of the same vehicle")
+	flag.StringVar((*string)(&config.Conflicts.SwipeResolution), "swipeResolution", string(config.Conflicts.SwipeResolution), "Reservation chosen when a swipe matches several: last, first, earliestEnd or reject")
+	flag.DurationVar(&config.Late.ReminderInterval, "lateReminderInterval", config.Late.ReminderInterval, "Time between the DelayedTripEnd reminders of a late driver")
+	flag.IntVar(&config.Late.MaxReminders, "lateMaxReminders", 0, "DelayedTripEnd reminders sent after the first late alarm")
+	flag.Int64Var(&config.Seed, "seed", 0, "Seed for all random values, a time based seed is chosen when not set")
+	flag.IntVar(&config.Load.Vehicles, "loadVehicles", 0, "Size of the synthetic fleet, starts the load mode when set")
+	flag.Float64Var(&config.Load.Rate, "loadRate", config.Load.Rate, "Reservations generated per minute in load mode")
+	flag.StringVar(&config.Load.OrgaNo, "loadOrga", config.Load.OrgaNo, "Orga of the synthetic fleet")
+	flag.DurationVar(&config.Load.TripDuration, "loadTripDuration", config.Load.TripDuration, "Duration of the generated reservations")
+	flag.Float64Var(&config.Load.LateRatio, "loadLateRatio", config.Load.LateRatio, "Share of generated trips returned late")
+	flag.Float64Var(&config.Load.NoShowRatio, "loadNoShowRatio", config.Load.NoShowRatio, "Share of generated reservations never driven")
+	flag.IntVar(&config.DefaultTimezone, "defaultTimezone", config.DefaultTimezone, "Invers timezone code used for messages without reservation")
+	flag.StringVar(&config.StateFile, "state", "", "JSON file the state is saved to at shutdown and resumed from at startup")
+	flag.DurationVar(&config.ShutdownTimeout, "shutdownTimeout", config.ShutdownTimeout, "Time given to send the pending messages and save the state at shutdown")
+	flag.BoolVar(&config.TLS.Enabled, "tls", false, "Serve HTTPS instead of HTTP")
+	flag.StringVar(&config.TLS.CertFile, "tlsCert", "", "PEM certificate of the HTTPS listener, also where a self-signed certificate is saved")
+	flag.StringVar(&config.TLS.KeyFile, "tlsKey", "", "PEM private key of the HTTPS listener, also where a self-signed key is saved")
+	flag.BoolVar(&config.TLS.SelfSigned, "tlsSelfSigned", false, "Generate a self-signed certificate unless the certificate files exist")
+	flag.StringVar(&config.TLS.Hosts, "tlsHosts", config.TLS.Hosts, "Comma separated host names and IPs of the self-signed certificate")
+	flag.StringVar(&config.TLS.ClientCAFile, "tlsClientCA", "", "PEM CA certificates the client certificates of /ComService and /AuthService must be signed by")
 	flag.Parse()
 
-	tc = interfaces.NewTripClient(takoEndpoint)
-	ts = usecases.NewTripService(tc, trips)
+	//the flags given on the command line win over the config file and the environment
+	explicit := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = f.Value.String() })
 
-	rc = interfaces.NewReservationClient(takoEndpoint)
-	rs = usecases.NewReservationService(rc, ts, reservations)
-	rl = interfaces.NewReservationListener(rs)
+	if configFile != "" {
+		if err := config.LoadFile(configFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	rl.Listen()
+	if err := config.LoadEnv(); err != nil {
+		log.Fatal(err)
+	}
 
-	log.Panic(http.ListenAndServe(":"+fmt.Sprint(port), nil))
+	for name, value := range explicit {
+		flag.Set(name, value)
+	}
+
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+
+	if config.Sink && config.TLS.Enabled {
+		config.TakoEndpoint = "https://localhost:" + fmt.Sprint(config.Port)
+	} else if config.Sink {
+		config.TakoEndpoint = "http://localhost:" + fmt.Sprint(config.Port)
+	}
+
+	//the timezones of the config win over the ones of the mapping file
+	if config.TimezonesFile != "" {
+		f, err := os.Open(config.TimezonesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		codes, err := domain.ReadTimezones(f)
+		f.Close()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if config.Timezones == nil {
+			config.Timezones = make(map[int]string)
+		}
+
+		for code, name := range codes {
+			if _, ok := config.Timezones[code]; !ok {
+				config.Timezones[code] = name
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Effective configuration:")
+	fmt.Print(config)
+
+	if config.TenantsFile != "" {
+		f, err := os.Open(config.TenantsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		tenants, err = domain.LoadTenants(f)
+		f.Close()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if config.CardsFile != "" {
+		f, err := os.Open(config.CardsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cards, err = domain.LoadCards(f)
+		f.Close()
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if config.TLS.Enabled {
+		var err error
+
+		if serverTLS, err = infrastructure.NewTLSConfig(config.TLS); err != nil {
+			log.Fatal(err)
+		}
+
+		//the sink receives the outbound messages on our own listener
+		if config.Sink {
+			if clientTLS, err = infrastructure.NewSelfTrustConfig(serverTLS); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if config.StateFile != "" {
+		f, err := os.Open(config.StateFile)
+		if err == nil {
+			state, err = domain.LoadState(f)
+			f.Close()
+		} else if os.IsNotExist(err) {
+			err = nil
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sim = simulator.New(simulator.Options{
+		TakoEndpoint:     config.TakoEndpoint,
+		InterfaceVersion: config.InterfaceVersion,
+		Tenants:          tenants,
+		Cards:            cards,
+		Seed:             config.Seed,
+		Settings:         &config.Settings,
+		Persona:          config.Persona,
+		Sink:             config.Sink,
+		CUCMAnswer:       config.SinkCUCM,
+		SinkMaxMessages:  config.SinkMaxMessages,
+		CUCMTimeout:      config.CUCMTimeout,
+		SendTimeout:      config.SendTimeout,
+		Conflicts:        config.Conflicts,
+		Late:             config.Late,
+		State:            state,
+		ClientTLS:        clientTLS,
+	})
+
+	if config.Load.Vehicles > 0 {
+		if err := sim.LoadService.Start(config.Load); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := &http.Server{Addr: ":" + fmt.Sprint(config.Port), Handler: sim.Handler(), TLSConfig: serverTLS}
+
+	//only the Invers services require a client certificate, the REST API stays open
+	if config.TLS.ClientCAFile != "" {
+		server.Handler = interfaces.RequireClientCertificate(server.Handler, "/ComService", "/AuthService")
+	}
+
+	go func() {
+		var err error
+
+		if serverTLS != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	fmt.Println("Received", <-signals, "- shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	//the listener stays open while the pending messages are sent, the sink receives them on it.
+	//Requests still handled while it closes may schedule more messages, they are sent afterwards.
+	if err := sim.Shutdown(ctx); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+
+	if err := sim.Scheduler.Flush(ctx); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+
+	if config.StateFile != "" {
+		if err := saveState(config.StateFile, sim.State()); err != nil {
+			fmt.Println("ERROR:", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("State saved to", config.StateFile)
+	}
+
+	fmt.Println("===== TAKO TECH SIMULATOR STOPPED =====")
+}
+
+// saveState writes the state next to the file first, a shutdown interrupted while writing keeps the previous one
+func saveState(path string, state *domain.State) error {
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = state.Save(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
 }
@@ -0,0 +1,129 @@
+package simtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Clock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*waiter
+	busy    func() bool
+}
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func NewClock(start time.Time) *Clock {
+	c := new(Clock)
+	c.now = start
+
+	return c
+}
+
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.waiters = append(c.waiters, &waiter{deadline: c.now.Add(d), ch: ch})
+	}
+
+	return ch
+}
+
+// SetBusyCheck lets Advance wait for work that runs without sleeping on the clock
+func (c *Clock) SetBusyCheck(f func() bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.busy = f
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.settle()
+
+	c.mutex.Lock()
+	target := c.now.Add(d)
+	c.mutex.Unlock()
+
+	for {
+		c.mutex.Lock()
+		sort.Slice(c.waiters, func(i, j int) bool {
+			return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+		})
+
+		if len(c.waiters) == 0 || c.waiters[0].deadline.After(target) {
+			c.now = target
+			c.mutex.Unlock()
+			return
+		}
+
+		//wake everything due at the next deadline, then let it run until it sleeps again
+		c.now = c.waiters[0].deadline
+		due := make([]*waiter, 0)
+		for len(c.waiters) > 0 && !c.waiters[0].deadline.After(c.now) {
+			due = append(due, c.waiters[0])
+			c.waiters = c.waiters[1:]
+		}
+		c.mutex.Unlock()
+
+		for _, w := range due {
+			w.ch <- w.deadline
+		}
+
+		c.settle()
+	}
+}
+
+// settle gives woken goroutines real time to run until the number of sleepers stops changing
+// and the busy check reports no more work
+func (c *Clock) settle() {
+	stable := 0
+
+	for i := 0; i < 500 && stable < 2; i++ {
+		count := c.waiting()
+
+		time.Sleep(time.Millisecond)
+
+		if count == c.waiting() && !c.isBusy() {
+			stable++
+		} else {
+			stable = 0
+		}
+	}
+}
+
+func (c *Clock) waiting() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.waiters)
+}
+
+func (c *Clock) isBusy() bool {
+	c.mutex.Lock()
+	busy := c.busy
+	c.mutex.Unlock()
+
+	return busy != nil && busy()
+}
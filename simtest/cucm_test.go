@@ -0,0 +1,61 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"testing"
+	"time"
+)
+
+func TestCUCMAnswerContinuesTheSwipe(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	card := domain.VirtualAccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(30 * time.Second)
+
+	pending := h.Simulator.ReservationService.GetCUCMRequests().Pending
+	if len(pending) != 1 {
+		t.Fatalf("%d pending CUCM requests", len(pending))
+	}
+	swipe := pending[0].Swipe.RequestId
+
+	cr := &domain.CUCMResponse{Guid: pending[0].Guid, Timezone: 105, VehicleDevice: domain.VehicleDevice{OrgaNo: "1", VehiclePhoneNo: "500"},
+		AccessDevice: card.GetAccessDevice(), ReservationId: "R1", StartTime: start, EndTime: start.Add(time.Hour)}
+	if err := h.Simulator.ReservationService.HandleNewCUCMResponse(cr); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	if _, err := h.WaitForEvent(domain.TRIP_START, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//the answered swipe keeps its task and its single status sequence
+	swipes := 0
+	for _, value := range h.Simulator.TaskService.GetTasks() {
+		if value.Type == domain.DRIVER_SWIPE_TASK {
+			swipes++
+		}
+	}
+	if swipes != 1 || pending[0].Swipe.RequestId != swipe {
+		t.Errorf("%d swipe tasks, swipe task %s became %s", swipes, swipe, pending[0].Swipe.RequestId)
+	}
+
+	done := 0
+	for _, value := range h.Simulator.TaskService.GetTask(swipe).History {
+		if value.Status == domain.RECEIVED {
+			done++
+		}
+	}
+	if done != 1 {
+		t.Errorf("swipe task %s done %d times", swipe, done)
+	}
+
+	if task := h.Simulator.TaskService.GetTask(cr.RequestId); task.SwipeTaskNumber != swipe || task.ReservationId != "R1" {
+		t.Errorf("CUCM task related to swipe %s and reservation %s", task.SwipeTaskNumber, task.ReservationId)
+	}
+}
@@ -0,0 +1,243 @@
+// Package simtest runs isolated simulator instances inside go test.
+//
+// Every Harness has its own mux, state, settings, random source and fake
+// clock, and serves the simulator on an httptest server, harnesses can run
+// in parallel. Outbound messages go to a local recorder unless a Tako
+// endpoint is given, and can be awaited with WaitForEvent.
+package simtest
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"github.com/leoride/tako-sim/interfaces"
+	"github.com/leoride/tako-sim/simulator"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Options struct {
+	Start            time.Time
+	TakoEndpoint     string
+	InterfaceVersion string
+	Tenants          []*domain.Tenant
+	Cards            []*domain.Card
+	Seed             int64
+	Settings         *domain.Settings
+	State            *domain.State
+}
+
+type Reservation struct {
+	ReservationId  string
+	OrgaNo         string
+	VehiclePhoneNo string
+	Card           domain.AccessDevice
+	Start          time.Time
+	End            time.Time
+	Timezone       int
+	LateAlarm      bool
+	LateBuffer     int
+}
+
+type Swipe struct {
+	OrgaNo         string
+	VehiclePhoneNo string
+	Card           domain.VirtualAccessDevice
+	PINs           []string
+}
+
+type Harness struct {
+	Simulator *simulator.Simulator
+	Clock     *Clock
+	URL       string
+
+	server *httptest.Server
+	tako   *httptest.Server
+
+	mutex    sync.Mutex
+	changed  chan struct{}
+	messages []*interfaces.SentMessage
+	seen     map[*interfaces.SentMessage]bool
+}
+
+func New(t testing.TB, o Options) *Harness {
+	h := new(Harness)
+
+	if o.Start.IsZero() {
+		o.Start = time.Now()
+	}
+
+	if o.TakoEndpoint == "" {
+		h.tako = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}))
+		o.TakoEndpoint = h.tako.URL
+	}
+
+	h.Clock = NewClock(o.Start)
+	h.changed = make(chan struct{})
+	h.messages = make([]*interfaces.SentMessage, 0)
+	h.seen = make(map[*interfaces.SentMessage]bool)
+
+	h.Simulator = simulator.New(simulator.Options{
+		TakoEndpoint:     o.TakoEndpoint,
+		InterfaceVersion: o.InterfaceVersion,
+		Tenants:          o.Tenants,
+		Cards:            o.Cards,
+		Clock:            h.Clock,
+		Seed:             o.Seed,
+		Settings:         o.Settings,
+		State:            o.State,
+	})
+	h.Simulator.OnSend(h.record)
+	h.Clock.SetBusyCheck(func() bool { return !h.Simulator.Scheduler.Idle() })
+
+	h.server = httptest.NewServer(h.Simulator.Handler())
+	h.URL = h.server.URL
+
+	if t != nil {
+		t.Cleanup(h.Close)
+	}
+
+	return h
+}
+
+// Close stops the servers and the scheduler of the simulator
+func (h *Harness) Close() {
+	h.server.Close()
+	h.Simulator.Close()
+
+	if h.tako != nil {
+		h.tako.Close()
+	}
+}
+
+func (h *Harness) AdvanceTime(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+func (h *Harness) CreateReservation(r Reservation) error {
+	body := fmt.Sprintf("<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body><SendReservation><task>"+
+		"<Destination><DestinationAddress><PhoneNo>%s</PhoneNo></DestinationAddress><OrgaNo>%s</OrgaNo></Destination>"+
+		"<TaskNumber>0</TaskNumber>"+
+		"<Reservation>"+
+		"<ReservationNo>%s</ReservationNo>"+
+		"<Start><Timezone>%d</Timezone><UTCDateTime>%s</UTCDateTime></Start>"+
+		"<Stop><Timezone>%d</Timezone><UTCDateTime>%s</UTCDateTime></Stop>"+
+		"<ReturnOptions><DelayMessage>%t</DelayMessage><DelayTime>%d</DelayTime></ReturnOptions>"+
+		"<UserAccessList><UserAccess><SerialNo>%s</SerialNo><CardNo>%s</CardNo><CardOrga>%s</CardOrga><Type>%s</Type><PIN>%s</PIN></UserAccess></UserAccessList>"+
+		"</Reservation>"+
+		"</task></SendReservation></s:Body></s:Envelope>",
+		r.VehiclePhoneNo, r.OrgaNo,
+		r.ReservationId,
+		r.Timezone, r.Start.UTC().Format(time.RFC3339),
+		r.Timezone, r.End.UTC().Format(time.RFC3339),
+		r.LateAlarm, r.LateBuffer,
+		r.Card.SmartcardSerialNo, r.Card.SmartcardCardNo, r.Card.SmartcardOrgaNo, r.Card.SmartcardType, r.Card.PIN)
+
+	return h.post("/ComService", body)
+}
+
+func (h *Harness) Swipe(s Swipe) error {
+	pins := ""
+	for _, value := range s.PINs {
+		pins += "<PIN>" + value + "</PIN>"
+	}
+
+	body := fmt.Sprintf("<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body><SendVirtualSmartCard><task>"+
+		"<Destination><DestinationAddress><PhoneNo>%s</PhoneNo></DestinationAddress><OrgaNo>%s</OrgaNo></Destination>"+
+		"<TaskNumber>0</TaskNumber>"+
+		"<VirtualSmartCard><CocosNumber>%s</CocosNumber><UserNumber>%s</UserNumber><OrgaRef>%s</OrgaRef><Type>%s</Type></VirtualSmartCard>"+
+		"<PINAttempts>%s</PINAttempts>"+
+		"</task></SendVirtualSmartCard></s:Body></s:Envelope>",
+		s.VehiclePhoneNo, s.OrgaNo,
+		s.Card.SmartcardSerialNo, s.Card.SmartcardCardNo, s.Card.SmartcardOrgaNo, s.Card.SmartcardType, pins)
+
+	return h.post("/ComService", body)
+}
+
+func (h *Harness) SendCommand(orgaNo string, vehiclePhoneNo string, ct domain.CommandType) error {
+	body := fmt.Sprintf("<s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body><SendCommand><task>"+
+		"<Destination><DestinationAddress><PhoneNo>%s</PhoneNo></DestinationAddress><OrgaNo>%s</OrgaNo></Destination>"+
+		"<TaskNumber>0</TaskNumber>"+
+		"<Command><Type>%s</Type></Command>"+
+		"</task></SendCommand></s:Body></s:Envelope>",
+		vehiclePhoneNo, orgaNo, ct)
+
+	return h.post("/ComService", body)
+}
+
+func (h *Harness) Messages() []*interfaces.SentMessage {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]*interfaces.SentMessage(nil), h.messages...)
+}
+
+// WaitFor returns the first message matching f that was not returned before
+func (h *Harness) WaitFor(f func(*interfaces.SentMessage) bool, timeout time.Duration) (*interfaces.SentMessage, error) {
+	deadline := time.After(timeout)
+
+	for {
+		h.mutex.Lock()
+		for _, msg := range h.messages {
+			if !h.seen[msg] && f(msg) {
+				h.seen[msg] = true
+				h.mutex.Unlock()
+
+				return msg, nil
+			}
+		}
+		changed := h.changed
+		h.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil, fmt.Errorf("No matching message received within %s", timeout)
+		}
+	}
+}
+
+func (h *Harness) WaitForEvent(en domain.EventName, timeout time.Duration) (*interfaces.SentMessage, error) {
+	return h.WaitFor(func(msg *interfaces.SentMessage) bool {
+		return strings.Contains(msg.Body, "<ns2:Description>"+string(en)+"</ns2:Description>")
+	}, timeout)
+}
+
+func (h *Harness) WaitForMessage(name string, timeout time.Duration) (*interfaces.SentMessage, error) {
+	return h.WaitFor(func(msg *interfaces.SentMessage) bool {
+		return msg.Name == name
+	}, timeout)
+}
+
+func (h *Harness) record(msg *interfaces.SentMessage) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.messages = append(h.messages, msg)
+
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+func (h *Harness) post(path string, body string) error {
+	resp, err := http.Post(h.URL+path, "text/xml", bytes.NewBufferString(body))
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, string(b))
+	}
+
+	return nil
+}
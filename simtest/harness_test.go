@@ -0,0 +1,148 @@
+package simtest
+
+import (
+	"context"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"github.com/leoride/tako-sim/interfaces"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+// playTrip books a reservation, swipes the card and returns the trip start
+func playTrip(t *testing.T, h *Harness, phoneNo string) *interfaces.SentMessage {
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardCardNo: "2", SmartcardOrgaNo: "3", SmartcardType: domain.MIFARE}
+
+	if err := h.CreateReservation(Reservation{ReservationId: "R" + phoneNo, OrgaNo: "1", VehiclePhoneNo: phoneNo, Card: card,
+		Start: start, End: start.Add(time.Hour), Timezone: 105, LateAlarm: true, LateBuffer: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: phoneNo, Card: card.GetVirtualAccessDevice()}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(5 * time.Minute)
+
+	msg, err := h.WaitForEvent(domain.TRIP_START, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return msg
+}
+
+func TestParallelHarnesses(t *testing.T) {
+	for i := 1; i <= 2; i++ {
+		i := i
+
+		t.Run(fmt.Sprint("harness", i), func(t *testing.T) {
+			t.Parallel()
+
+			settings := domain.DefaultSettings()
+			settings.Identifiers.CustomerId = fmt.Sprintf("00000000-0000-0000-0000-00000000000%d", i)
+			settings.Timings.TripStart = time.Duration(i) * time.Minute
+
+			h := New(t, Options{Start: start, Seed: int64(i), Settings: &settings})
+			msg := playTrip(t, h, fmt.Sprint(500+i))
+
+			//each harness keeps its own trip start delay after the swipe at 10:01
+			if want := start.Add(time.Minute + settings.Timings.TripStart); !msg.Time.Equal(want) {
+				t.Errorf("trip start sent at %s, want %s", msg.Time, want)
+			}
+
+			for _, value := range h.Messages() {
+				if strings.Contains(value.Body, "<a:CustomerId>") && !strings.Contains(value.Body, settings.Identifiers.CustomerId) {
+					t.Errorf("%s without the customer id of its own harness: %s", value.Name, value.Body)
+				}
+			}
+
+			if h.Simulator.Environment.Random.GetSeed() != int64(i) {
+				t.Errorf("seed %d, want %d", h.Simulator.Environment.Random.GetSeed(), i)
+			}
+		})
+	}
+}
+
+// TestTripLifecycle drives a trip to its end, the segment timer and the trip end are due together,
+// run it with -race
+func TestTripLifecycle(t *testing.T) {
+	h := New(t, Options{Start: start})
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardCardNo: "2", SmartcardOrgaNo: "3", SmartcardType: domain.MIFARE}
+
+	playTrip(t, h, "500")
+	h.AdvanceTime(time.Hour)
+
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card.GetVirtualAccessDevice()}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(10 * time.Minute)
+
+	for _, value := range []domain.EventName{domain.LATE_DRIVER, domain.TRIP_END} {
+		if _, err := h.WaitForEvent(value, time.Second); err != nil {
+			t.Fatal(value, err)
+		}
+	}
+
+	for _, value := range []string{"trip data", "trip complete"} {
+		if _, err := h.WaitForMessage(value, time.Second); err != nil {
+			t.Fatal(value, err)
+		}
+	}
+}
+
+func TestCloseStopsScheduler(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	h := New(nil, Options{Start: start})
+	playTrip(t, h, "500")
+	h.Close()
+
+	//the stopped workers and the idle HTTP connections need a moment to exit
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before+2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before+2 {
+		t.Errorf("%d goroutines left after Close, %d before the harness", after, before)
+	}
+}
+
+func TestShutdownDeadlineCancelsSends(t *testing.T) {
+	release := make(chan struct{})
+	tako := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer tako.Close()
+	defer close(release)
+
+	h := New(t, Options{Start: start, TakoEndpoint: tako.URL})
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+	if err := h.Simulator.Shutdown(ctx); err == nil {
+		t.Error("no error for the status updates left at the deadline")
+	}
+
+	if d := time.Since(began); d > 2*time.Second {
+		t.Errorf("shutdown took %s with a 200ms deadline", d)
+	}
+}
@@ -0,0 +1,45 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"testing"
+	"time"
+)
+
+func TestLoadRestartKeepsTheRate(t *testing.T) {
+	h := New(t, Options{Start: start})
+	o := domain.LoadOptions{OrgaNo: "1", Vehicles: 50, Rate: 1, TripDuration: 30 * time.Minute}
+
+	if err := h.Simulator.LoadService.Start(o); err != nil {
+		t.Fatal(err)
+	}
+	h.AdvanceTime(0)
+
+	//restarted within one interval, the chain of the first run must stop
+	h.Simulator.LoadService.Stop()
+	if err := h.Simulator.LoadService.Start(o); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		h.AdvanceTime(time.Minute)
+	}
+
+	//one reservation right away and one per minute
+	if s := h.Simulator.LoadService.GetStats(); s.Reservations != 11 {
+		t.Errorf("%d reservations in 10 minutes at 1 per minute", s.Reservations)
+	}
+}
+
+func TestLoadRatios(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	for _, value := range [][2]float64{{-0.1, 0}, {0, -0.1}, {0.6, 0.5}} {
+		o := domain.LoadOptions{OrgaNo: "1", Vehicles: 1, Rate: 1, TripDuration: 30 * time.Minute, LateRatio: value[0], NoShowRatio: value[1]}
+
+		if err := h.Simulator.LoadService.Start(o); err == nil {
+			t.Errorf("late ratio %v and no-show ratio %v accepted", value[0], value[1])
+			h.Simulator.LoadService.Stop()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPersonaOfCard(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	//Hitag cards are identified by card and orga number, the serial number differs
+	assigned := domain.AccessDevice{SmartcardSerialNo: "A", SmartcardCardNo: "2", SmartcardOrgaNo: "3", SmartcardType: domain.HITAG_16}
+	if err := h.Simulator.PersonaService.AssignCard(assigned, "punctual"); err != nil {
+		t.Fatal(err)
+	}
+
+	card := domain.AccessDevice{SmartcardSerialNo: "B", SmartcardCardNo: "2", SmartcardOrgaNo: "3", SmartcardType: "hitag-16"}
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	//moved an hour later before its start, the persona follows the new start
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start.Add(2 * time.Hour), End: start.Add(3 * time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 130; i++ {
+		h.AdvanceTime(time.Minute)
+	}
+
+	msg, err := h.WaitForEvent(domain.TRIP_START, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Time.Before(start.Add(2 * time.Hour)) {
+		t.Errorf("trip started at %s, before the new reservation start", msg.Time)
+	}
+}
+
+func TestPersonaWithoutLateAlarm(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	if err := h.Simulator.PersonaService.Assign("default", "", "lateNoAlarm"); err != nil {
+		t.Fatal(err)
+	}
+
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+	booking := Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start.Add(time.Minute), End: start.Add(30 * time.Minute), Timezone: 105, LateAlarm: true, LateBuffer: 5}
+	if err := h.CreateReservation(booking); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 70; i++ {
+		h.AdvanceTime(time.Minute)
+	}
+
+	if _, err := h.WaitForEvent(domain.TRIP_END, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range h.Messages() {
+		if strings.Contains(value.Body, "<ns2:Description>"+string(domain.LATE_DRIVER)+"<") {
+			t.Errorf("late alarm sent at %s", value.Time)
+		}
+	}
+
+	//the booking sent again is unchanged, the persona left the reservation alone
+	if err := h.CreateReservation(booking); err != nil {
+		t.Fatal(err)
+	}
+	if r := h.Simulator.ReservationService.GetReservation("R1"); !r.LateAlarm || len(r.History) != 0 {
+		t.Errorf("late alarm %v with changes %+v", r.LateAlarm, r.History)
+	}
+}
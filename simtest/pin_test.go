@@ -0,0 +1,99 @@
+package simtest
+
+import (
+	"bytes"
+	"github.com/leoride/tako-sim/domain"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwipeWithoutPINAttempts(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE, PIN: "1234"}
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	//a board computer without PIN entry swipes without attempts, the trip starts as before
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card.GetVirtualAccessDevice()}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	if _, err := h.WaitForEvent(domain.TRIP_START, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := h.Simulator.CardService.GetCard(card); c != nil && c.PINFailures != 0 {
+		t.Errorf("swipe without attempts counted as %d wrong PINs", c.PINFailures)
+	}
+
+	//a wrong attempt is still refused
+	if err := h.CreateReservation(Reservation{ReservationId: "R2", OrgaNo: "1", VehiclePhoneNo: "501", Card: card,
+		Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "501", Card: card.GetVirtualAccessDevice(), PINs: []string{"0000"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	if _, err := h.WaitForEvent(domain.REJECTED_ACCESS, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPINsStayOutOfTheAPI(t *testing.T) {
+	registered := &domain.Card{AccessDevice: domain.AccessDevice{SmartcardSerialNo: "2", SmartcardType: domain.MIFARE, PIN: "5678"}}
+	h := New(t, Options{Start: start, Cards: []*domain.Card{registered}})
+
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE, PIN: "1234"}
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card.GetVirtualAccessDevice(), PINs: []string{"1234"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	for _, value := range []string{"/reservations/", "/reservations/R1", "/tasks", "/cards"} {
+		resp, err := http.Get(h.URL + value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(b), "1234") || strings.Contains(string(b), "5678") {
+			t.Errorf("%s shows a PIN: %s", value, b)
+		}
+	}
+
+	//the saved state keeps them
+	var b bytes.Buffer
+	if err := h.Simulator.State().Save(&b); err != nil {
+		t.Fatal(err)
+	}
+	state, err := domain.LoadState(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Reservations[0].AccessDevice.PIN != "1234" || state.Cards[0].PIN != "5678" {
+		t.Errorf("restored PINs %s and %s", state.Reservations[0].AccessDevice.PIN, state.Cards[0].PIN)
+	}
+}
@@ -0,0 +1,71 @@
+package simtest
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"runtime"
+	"testing"
+	"time"
+)
+
+const scaleReservations = 100000
+
+// scheduleReservations books n reservations spread over 1000 vehicles, one second apart
+func scheduleReservations(tb testing.TB, h *Harness, n int) {
+	for i := 0; i < n; i++ {
+		r := new(domain.Reservation)
+		r.ReservationId = fmt.Sprint("R", i)
+		r.VehicleDevice = domain.VehicleDevice{VehiclePhoneNo: fmt.Sprint(i % 1000), OrgaNo: "1"}
+		r.AccessDevice = domain.AccessDevice{SmartcardSerialNo: fmt.Sprint(i), SmartcardType: domain.MIFARE}
+		r.StartTime = start.Add(time.Duration(i) * time.Second)
+		r.EndTime = r.StartTime.Add(time.Hour)
+		r.Timezone = 105
+
+		if err := h.Simulator.ReservationService.HandleNewReservation(r); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+func TestScheduleHundredThousandReservations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scale test")
+	}
+
+	h := New(t, Options{Start: start})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	goroutines := runtime.NumGoroutine()
+
+	scheduleReservations(t, h, scaleReservations)
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	//the timers wait in the queue, not in goroutines
+	if n := runtime.NumGoroutine(); n > goroutines+10 {
+		t.Errorf("%d goroutines for %d reservations, %d before", n, scaleReservations, goroutines)
+	}
+
+	if jobs := h.Simulator.Scheduler.Len(); jobs < scaleReservations {
+		t.Errorf("%d jobs scheduled for %d reservations", jobs, scaleReservations)
+	}
+
+	perReservation := (after.HeapAlloc - before.HeapAlloc) / scaleReservations
+	t.Logf("%d jobs, %d goroutines, %d bytes per reservation", h.Simulator.Scheduler.Len(), runtime.NumGoroutine(), perReservation)
+
+	if perReservation > 4*1024 {
+		t.Errorf("%d bytes of heap per reservation", perReservation)
+	}
+}
+
+func BenchmarkScheduleReservations(b *testing.B) {
+	h := New(b, Options{Start: start})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	scheduleReservations(b, h, b.N)
+}
@@ -0,0 +1,111 @@
+package simtest
+
+import (
+	"bytes"
+	"github.com/leoride/tako-sim/domain"
+	"testing"
+	"time"
+)
+
+func TestResumeContinuesTaskNumbers(t *testing.T) {
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+
+	h := New(t, Options{Start: start})
+	for _, value := range []string{"R1", "R2"} {
+		if err := h.CreateReservation(Reservation{ReservationId: value, OrgaNo: "1", VehiclePhoneNo: value, Card: card,
+			Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := h.Simulator.State().Save(&b); err != nil {
+		t.Fatal(err)
+	}
+	saved := b.String()
+
+	//a state saved without the last task number continues after its reservations
+	old, err := domain.LoadState(bytes.NewBufferString(saved))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.LastTaskNumber = 0
+
+	b.Reset()
+	if err := old.Save(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{saved, b.String()} {
+		state, err := domain.LoadState(bytes.NewBufferString(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resumed := New(t, Options{Start: start, State: state})
+		if err := resumed.CreateReservation(Reservation{ReservationId: "R3", OrgaNo: "1", VehiclePhoneNo: "R3", Card: card,
+			Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+			t.Fatal(err)
+		}
+
+		if task := resumed.Simulator.TaskService.GetTask("3"); task == nil || task.Type != domain.RESERVATION_TASK {
+			t.Errorf("new reservation is not task 3: %v", resumed.Simulator.TaskService.GetTasks())
+		}
+	}
+}
+
+func TestResumeContinuesPersonas(t *testing.T) {
+	h := New(nil, Options{Start: start})
+
+	if err := h.Simulator.PersonaService.UpdatePersona(&domain.Persona{Name: "commuter", StartDelay: 2, ReturnOffset: -5, Stops: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+	if err := h.Simulator.PersonaService.AssignCard(first, "commuter"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Simulator.PersonaService.Assign("reservation", "R2", "punctual"); err != nil {
+		t.Fatal(err)
+	}
+
+	//R1 is driven when the state is saved, R2 has not started yet
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: first,
+		Start: start.Add(10 * time.Minute), End: start.Add(40 * time.Minute), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.CreateReservation(Reservation{ReservationId: "R2", OrgaNo: "1", VehiclePhoneNo: "501", Card: domain.AccessDevice{SmartcardSerialNo: "2", SmartcardType: domain.MIFARE},
+		Start: start.Add(60 * time.Minute), End: start.Add(90 * time.Minute), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		h.AdvanceTime(time.Minute)
+	}
+	if trip := h.Simulator.ReservationService.GetReservation("R1").GetCurrentTrip(); trip == nil || trip.Status != domain.IN_PROGRESS {
+		t.Fatalf("R1 not driven when the state is saved: %v", trip)
+	}
+
+	var b bytes.Buffer
+	if err := h.Simulator.State().Save(&b); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	state, err := domain.LoadState(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := New(t, Options{Start: h.Clock.Now(), State: state})
+	for i := 0; i < 100; i++ {
+		resumed.AdvanceTime(time.Minute)
+	}
+
+	for _, value := range []string{"R1", "R2"} {
+		r := resumed.Simulator.ReservationService.GetReservation(value)
+		if len(r.Trips) != 1 || r.Trips[0].Status != domain.COMPLETED {
+			t.Errorf("%s has %d trips, the last one %v", value, len(r.Trips), r.GetCurrentTrip())
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDisabledFeatureRejectsTheTask(t *testing.T) {
+	tako := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tako.Close()
+
+	tenant := domain.NewTenant("1", tako.URL)
+	tenant.Features = []domain.Feature{domain.RESERVATIONS}
+
+	h := New(t, Options{Start: start, Tenants: []*domain.Tenant{tenant}})
+
+	card := domain.VirtualAccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	msg, err := h.WaitForMessage("status update Done", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(msg.Body, "<a:TaskError>"+string(domain.FEATURE_NOT_ENABLED)+"<") {
+		t.Errorf("swipe without virtual cards not rejected: %s", msg.Body)
+	}
+
+	for _, value := range h.Messages() {
+		if strings.Contains(value.Name, "CUCM request") || strings.Contains(value.Name, "rejected access") {
+			t.Errorf("%s sent for a rejected swipe", value.Name)
+		}
+	}
+
+	//unknown orgas have no Tako to answer to
+	if err := h.Swipe(Swipe{OrgaNo: "2", VehiclePhoneNo: "500", Card: card}); err == nil {
+		t.Error("swipe of an unknown orga accepted")
+	}
+}
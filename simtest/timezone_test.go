@@ -0,0 +1,22 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"testing"
+	"time"
+)
+
+func TestReservationWithoutTimezone(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := h.Simulator.ReservationService.GetReservation("R1")
+	if d := h.Simulator.Environment.Settings.DefaultTimezone; r.Timezone != d || r.GetTimezone(h.Simulator.Environment).String() == "Etc/GMT+12" {
+		t.Errorf("reservation without timezone in code %d, want the default %d", r.Timezone, d)
+	}
+}
@@ -0,0 +1,68 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwipeMessagesCarryTheVehicle(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	v := h.Simulator.VehicleService.GetOrCreateVehicle(domain.VehicleDevice{VehiclePhoneNo: "500", OrgaNo: "1"})
+	h.Simulator.VehicleService.UpdateVehicle(&domain.Vehicle{VehicleDevice: v.VehicleDevice, Fuel: 42, Odometer: 1234, Latitude: 48.8566, Longitude: 2.3522})
+
+	card := domain.AccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE, PIN: "1234"}
+	if err := h.CreateReservation(Reservation{ReservationId: "R1", OrgaNo: "1", VehiclePhoneNo: "500", Card: card,
+		Start: start, End: start.Add(time.Hour), Timezone: 105}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	//the PIN is wrong, the access is rejected
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card.GetVirtualAccessDevice(), PINs: []string{"0000"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	//a card without reservation asks CUCM
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: domain.VirtualAccessDevice{SmartcardSerialNo: "9", SmartcardType: domain.MIFARE}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	msg, err := h.WaitForEvent(domain.REJECTED_ACCESS, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"<ns3:SourceNo>" + v.SourceNo + "<", "<ns3:Fuel>42<", "<ns3:Mileage>1234<", "<ns3:Latitude>48.8566<", "<ns3:Longitude>2.3522<"} {
+		if !strings.Contains(msg.Body, value) {
+			t.Errorf("rejected access without %s: %s", value, msg.Body)
+		}
+	}
+
+	msg, err = h.WaitForMessage("CUCM request", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(msg.Body, "<ns3:SourceNo>"+v.SourceNo+"<") {
+		t.Errorf("CUCM request without the source number %s: %s", v.SourceNo, msg.Body)
+	}
+}
+
+func TestUpdateVehicleKeepsTheSourceNo(t *testing.T) {
+	h := New(t, Options{Start: start})
+
+	v := h.Simulator.VehicleService.GetOrCreateVehicle(domain.VehicleDevice{VehiclePhoneNo: "500", OrgaNo: "1"})
+	sourceNo := v.SourceNo
+
+	updated := h.Simulator.VehicleService.UpdateVehicle(&domain.Vehicle{VehicleDevice: v.VehicleDevice, SourceNo: "1", IgnitionStatus: true, Fuel: 10})
+
+	if updated != v || v.SourceNo != sourceNo || v.IgnitionStatus || v.Fuel != 10 {
+		t.Errorf("vehicle updated to %+v", v)
+	}
+}
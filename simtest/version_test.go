@@ -0,0 +1,35 @@
+package simtest
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVehicleInterfaceVersion(t *testing.T) {
+	h := New(t, Options{Start: start, InterfaceVersion: "21"})
+
+	v := domain.NewVehicle(h.Simulator.Environment.Random, domain.VehicleDevice{VehiclePhoneNo: "500", OrgaNo: "1"})
+	v.InterfaceVersion = "22"
+	h.Simulator.VehicleService.UpdateVehicle(v)
+
+	//the status updates of a swipe without reservation and its CUCM request follow the vehicle
+	card := domain.VirtualAccessDevice{SmartcardSerialNo: "1", SmartcardType: domain.MIFARE}
+	if err := h.Swipe(Swipe{OrgaNo: "1", VehiclePhoneNo: "500", Card: card}); err != nil {
+		t.Fatal(err)
+	}
+
+	h.AdvanceTime(time.Minute)
+
+	for _, name := range []string{"status update SendToCUCM", "CUCM request"} {
+		msg, err := h.WaitForMessage(name, time.Second)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if !strings.Contains(msg.URL, "/ws/invers/22/") {
+			t.Errorf("%s sent to %s, want version 22", name, msg.URL)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package simulator
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"github.com/leoride/tako-sim/infrastructure"
+	"github.com/leoride/tako-sim/interfaces"
+	"github.com/leoride/tako-sim/usecases"
+	"net/http"
+	"time"
+)
+
+type Options struct {
+	TakoEndpoint     string
+	InterfaceVersion string
+	Tenants          []*domain.Tenant
+	Cards            []*domain.Card
+	Clock            domain.Clock
+	Seed             int64
+	Settings         *domain.Settings //timings, identifiers and timezones, the defaults when not set
+	Persona          string
+	Sink             bool
+	CUCMAnswer       domain.CUCMAnswer
+	SinkMaxMessages  int
+	CUCMTimeout      time.Duration
+	SendTimeout      time.Duration
+	Conflicts        domain.ConflictOptions
+	Late             domain.LateOptions
+	State            *domain.State
+	ClientTLS        *tls.Config
+}
+
+type Simulator struct {
+	Clock       domain.Clock
+	Scheduler   *usecases.Scheduler
+	Environment *domain.Environment
+
+	TenantService      *usecases.TenantService
+	TaskService        *usecases.TaskService
+	CardService        *usecases.CardService
+	VehicleService     *usecases.VehicleService
+	TripService        *usecases.TripService
+	ReservationService *usecases.ReservationService
+	SinkService        *usecases.SinkService
+	LoadService        *usecases.LoadService
+	PersonaService     *usecases.PersonaService
+
+	TripClient        *interfaces.TripClient
+	ReservationClient *interfaces.ReservationClient
+
+	mux *http.ServeMux
+}
+
+func New(o Options) *Simulator {
+	var (
+		vl *interfaces.VehicleListener
+		rl *interfaces.ReservationListener
+
+		reservations []*domain.Reservation = make([]*domain.Reservation, 0)
+		trips        []*domain.Trip        = make([]*domain.Trip, 0)
+		vehicles     []*domain.Vehicle     = make([]*domain.Vehicle, 0)
+		tenants      []*domain.Tenant      = o.Tenants
+	)
+
+	if o.Clock == nil {
+		o.Clock = infrastructure.NewSystemClock()
+	}
+
+	//the default seed of a simulator started without one
+	if o.Seed == 0 {
+		o.Seed = 1
+	}
+
+	if o.Settings == nil {
+		settings := domain.DefaultSettings()
+		o.Settings = &settings
+	}
+
+	if o.InterfaceVersion == "" {
+		o.InterfaceVersion = domain.DEFAULT_INTERFACE_VERSION
+	}
+
+	if tenants == nil {
+		tenants = make([]*domain.Tenant, 0)
+	}
+
+	//a saved state replaces the cards given at startup, they may have been changed or blocked since
+	if o.State != nil {
+		vehicles = o.State.Vehicles
+		reservations = o.State.Reservations
+		trips = o.State.Trips
+		o.Cards = o.State.Cards
+	}
+
+	s := new(Simulator)
+	s.Clock = o.Clock
+	s.mux = http.NewServeMux()
+
+	env, err := domain.NewEnvironment(*o.Settings, o.Seed)
+	if err != nil {
+		fmt.Println("ERROR:", err, "- using the default settings")
+		env = domain.DefaultEnvironment(o.Seed)
+	}
+	s.Environment = env
+
+	s.TenantService = usecases.NewTenantService(o.TakoEndpoint, o.InterfaceVersion, tenants)
+
+	s.Scheduler = usecases.NewScheduler(s.Clock)
+	s.TaskService = usecases.NewTaskService(s.Clock)
+	s.CardService = usecases.NewCardService(o.Cards)
+
+	s.VehicleService = usecases.NewVehicleService(env, vehicles)
+	vl = interfaces.NewVehicleListener(s.VehicleService)
+
+	s.TripClient = interfaces.NewTripClient(s.TenantService, s.VehicleService, env, s.Clock)
+	s.TripService = usecases.NewTripService(s.TripClient, s.VehicleService, env, s.Clock, s.Scheduler, trips)
+
+	s.ReservationClient = interfaces.NewReservationClient(s.TenantService, s.VehicleService, env, s.Clock)
+	s.ReservationService = usecases.NewReservationService(s.ReservationClient, s.TripService, s.VehicleService, s.TenantService, s.TaskService, s.CardService, env, s.Clock, s.Scheduler, reservations)
+	rl = interfaces.NewReservationListener(s.ReservationService, env, s.Clock)
+
+	if o.CUCMTimeout > 0 {
+		s.ReservationService.SetCUCMTimeout(o.CUCMTimeout)
+	}
+
+	if o.Conflicts.SwipeResolution == "" {
+		o.Conflicts.SwipeResolution = domain.SWIPE_LAST
+	}
+	if err := s.ReservationService.SetConflictOptions(o.Conflicts); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+	if err := s.ReservationService.SetLateOptions(o.Late); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+
+	s.PersonaService = usecases.NewPersonaService(s.ReservationService, s.TripService, env, s.Clock, s.Scheduler, o.Persona)
+	s.LoadService = usecases.NewLoadService(s.ReservationService, env, s.Clock, s.Scheduler)
+	if o.SendTimeout > 0 {
+		s.TripClient.SetTimeout(o.SendTimeout)
+		s.ReservationClient.SetTimeout(o.SendTimeout)
+	}
+	if o.ClientTLS != nil {
+		s.TripClient.SetTLSConfig(o.ClientTLS)
+		s.ReservationClient.SetTLSConfig(o.ClientTLS)
+	}
+
+	s.OnSend(func(msg *interfaces.SentMessage) {
+		s.LoadService.RecordResponse(msg.Name, msg.Error != "" || msg.Status >= 300, msg.Duration)
+	})
+
+	rl.Listen(s.mux)
+	vl.Listen(s.mux)
+	interfaces.NewLoadListener(s.LoadService).Listen(s.mux)
+	interfaces.NewPersonaListener(s.PersonaService).Listen(s.mux)
+	interfaces.NewTaskListener(s.TaskService).Listen(s.mux)
+	interfaces.NewTripListener(s.TripService).Listen(s.mux)
+	interfaces.NewCardListener(s.CardService).Listen(s.mux)
+
+	//sink mode receives the outbound messages locally instead of a Tako
+	if o.Sink {
+		s.SinkService = usecases.NewSinkService(s.ReservationService, env, s.Clock, s.Scheduler, o.CUCMAnswer)
+		if o.SinkMaxMessages > 0 {
+			s.SinkService.SetMaxMessages(o.SinkMaxMessages)
+		}
+		interfaces.NewSinkListener(s.SinkService).Listen(s.mux)
+	}
+
+	if o.State != nil {
+		s.TaskService.Resume(o.State.LastTaskNumber)
+		s.TripService.Resume()
+		s.ReservationService.Resume(o.State.CUCMRequests)
+		s.PersonaService.Resume(o.State.Personas)
+
+		fmt.Println("Resumed state saved at", o.State.SavedTime, "with", len(reservations), "reservations and", len(vehicles), "vehicles")
+	}
+
+	return s
+}
+
+// Shutdown stops the timers and sends the pending outbound messages before the deadline of ctx,
+// the requests still running at the deadline are cancelled
+func (s *Simulator) Shutdown(ctx context.Context) error {
+	s.TripClient.SetContext(ctx)
+	s.ReservationClient.SetContext(ctx)
+
+	return s.Scheduler.Shutdown(ctx)
+}
+
+// Close releases the goroutines of the simulator, the pending messages are dropped
+func (s *Simulator) Close() {
+	s.Scheduler.Stop()
+}
+
+// State takes the snapshot a restarted simulator resumes from
+func (s *Simulator) State() *domain.State {
+	return &domain.State{
+		SavedTime:    s.Clock.Now(),
+		Vehicles:     s.VehicleService.GetVehicles(),
+		Cards:        s.CardService.GetCards(),
+		Reservations: s.ReservationService.GetReservations(),
+		Trips:        s.TripService.GetTrips(),
+		CUCMRequests: s.ReservationService.GetCUCMRequests().Pending,
+		Personas:     s.PersonaService.GetState(),
+
+		LastTaskNumber: s.TaskService.GetLastTaskNumber(),
+	}
+}
+
+func (s *Simulator) Handler() http.Handler {
+	return s.mux
+}
+
+func (s *Simulator) OnSend(f func(*interfaces.SentMessage)) {
+	s.TripClient.OnSend(f)
+	s.ReservationClient.OnSend(f)
+}
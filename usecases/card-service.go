@@ -0,0 +1,130 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+	"time"
+)
+
+type CardService struct {
+	mutex sync.Mutex
+	cards []*domain.Card
+}
+
+func NewCardService(cards []*domain.Card) *CardService {
+	cs := new(CardService)
+
+	cs.cards = make([]*domain.Card, 0)
+	for _, value := range cards {
+		if _, err := cs.UpdateCard(value); err != nil {
+			fmt.Println("ERROR:", err)
+		}
+	}
+
+	return cs
+}
+
+func (cs *CardService) GetCards() []*domain.Card {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	return append([]*domain.Card(nil), cs.cards...)
+}
+
+func (cs *CardService) GetCard(a domain.AccessDevice) *domain.Card {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	return cs.getCard(a)
+}
+
+// UpdateCard registers a card or replaces the registered card with the same identity
+func (cs *CardService) UpdateCard(c *domain.Card) (*domain.Card, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	c.AccessDevice = c.AccessDevice.Normalise()
+
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if existing := cs.getCard(c.AccessDevice); existing != nil {
+		*existing = *c
+		fmt.Println("Card updated:", c.AccessDevice)
+
+		return existing, nil
+	}
+
+	cs.cards = append(cs.cards, c)
+	fmt.Println("Card registered:", c.AccessDevice)
+
+	return c, nil
+}
+
+func (cs *CardService) DeleteCard(a domain.AccessDevice) bool {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	for i, value := range cs.cards {
+		if value.Matches(a) {
+			cs.cards = append(cs.cards[:i], cs.cards[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckAccess evaluates a card against the registry, unregistered cards are not rejected here
+func (cs *CardService) CheckAccess(a domain.AccessDevice, vd domain.VehicleDevice, now time.Time) domain.RejectionReason {
+	c := cs.GetCard(a)
+
+	if c == nil {
+		return ""
+	}
+
+	return c.Check(vd, now)
+}
+
+// CheckPIN plays the PIN attempts of a swipe, the PIN of the reservation takes precedence over the PIN
+// of the registered card. Unregistered cards are registered on their first wrong PIN to count the tries.
+func (cs *CardService) CheckPIN(a domain.AccessDevice, pin string, attempts []string) (domain.PINResult, int) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	c := cs.getCard(a)
+
+	if c == nil {
+		c = &domain.Card{AccessDevice: a.Normalise()}
+	}
+
+	if pin == "" {
+		pin = string(c.PIN)
+	}
+
+	result, tries, failed := domain.CheckPIN(pin, attempts, c.PINFailures, c.MaxPINTries)
+
+	if failed > 0 && cs.getCard(a) == nil {
+		cs.cards = append(cs.cards, c)
+	}
+
+	c.PINFailures = failed
+	if result == domain.PIN_BLOCKED && !c.Blocked {
+		c.Blocked = true
+		fmt.Println("Card blocked after", failed, "wrong PINs:", c.AccessDevice)
+	}
+
+	return result, tries
+}
+
+func (cs *CardService) getCard(a domain.AccessDevice) *domain.Card {
+	for _, value := range cs.cards {
+		if value.Matches(a) {
+			return value
+		}
+	}
+
+	return nil
+}
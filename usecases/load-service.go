@@ -0,0 +1,271 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+	"time"
+)
+
+type LoadService struct {
+	reservationService *ReservationService
+	env                *domain.Environment
+	clock              domain.Clock
+	scheduler          *Scheduler
+
+	mutex    sync.Mutex
+	options  domain.LoadOptions
+	stats    *domain.LoadStats
+	vehicles []*loadVehicle
+	running  bool
+	run      int //the generate and report chains of an earlier run stop at the next step
+
+	lastReservation int
+}
+
+type loadVehicle struct {
+	VehicleDevice domain.VehicleDevice
+	AccessDevice  domain.AccessDevice
+	Busy          bool
+}
+
+func NewLoadService(rs *ReservationService, env *domain.Environment, clock domain.Clock, scheduler *Scheduler) *LoadService {
+	ls := new(LoadService)
+
+	ls.reservationService = rs
+	ls.env = env
+	ls.clock = clock
+	ls.scheduler = scheduler
+	ls.stats = domain.NewLoadStats(ls.options, clock.Now())
+
+	return ls
+}
+
+// Start creates the synthetic fleet and keeps generating reservations until Stop is called
+func (ls *LoadService) Start(o domain.LoadOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	if ls.running {
+		return fmt.Errorf("Load mode is already running")
+	}
+
+	ls.options = o
+	ls.stats = domain.NewLoadStats(o, ls.clock.Now())
+	ls.vehicles = make([]*loadVehicle, 0)
+	ls.running = true
+	ls.run++
+	run := ls.run
+
+	for i := 1; i <= o.Vehicles; i++ {
+		lv := new(loadVehicle)
+		lv.VehicleDevice = domain.VehicleDevice{VehiclePhoneNo: fmt.Sprintf("LOAD%06d", i), OrgaNo: o.OrgaNo}
+		lv.AccessDevice = domain.AccessDevice{
+			SmartcardSerialNo: fmt.Sprint(9000000 + i),
+			SmartcardCardNo:   fmt.Sprint(i),
+			SmartcardOrgaNo:   o.OrgaNo,
+			SmartcardType:     domain.MIFARE,
+		}
+
+		ls.vehicles = append(ls.vehicles, lv)
+	}
+
+	fmt.Println("Load mode started with", o.Vehicles, "vehicles at", o.Rate, "reservations per minute")
+
+	ls.scheduler.After(0, func() { ls.generate(run) })
+	ls.scheduler.After(10*time.Second, func() { ls.report(run) })
+
+	return nil
+}
+
+func (ls *LoadService) Stop() {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	ls.running = false
+}
+
+func (ls *LoadService) SetRate(rate float64) error {
+	if rate <= 0 {
+		return fmt.Errorf("Rate must be positive")
+	}
+
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	ls.options.Rate = rate
+	ls.stats.Options.Rate = rate
+
+	return nil
+}
+
+func (ls *LoadService) GetStats() *domain.LoadStats {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	s := *ls.stats
+	s.ScheduledJobs = ls.scheduler.Len()
+	total := *ls.stats.Total
+	s.Total = &total
+	s.Messages = make(map[string]*domain.MessageStats)
+
+	for key, value := range ls.stats.Messages {
+		ms := *value
+		s.Messages[key] = &ms
+	}
+
+	return &s
+}
+
+// RecordResponse adds the outcome of one message sent to Tako to the statistics
+func (ls *LoadService) RecordResponse(name string, failed bool, d time.Duration) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	ms := ls.stats.Messages[name]
+	if ms == nil {
+		ms = new(domain.MessageStats)
+		ls.stats.Messages[name] = ms
+	}
+
+	ms.Add(failed, d)
+	ls.stats.Total.Add(failed, d)
+}
+
+// current tells whether run is the running load run
+func (ls *LoadService) current(run int) bool {
+	return ls.running && ls.run == run
+}
+
+func (ls *LoadService) generate(run int) {
+	ls.mutex.Lock()
+	running := ls.current(run)
+	interval := time.Duration(float64(time.Minute) / ls.options.Rate)
+	ls.mutex.Unlock()
+
+	if !running {
+		return
+	}
+
+	ls.newReservation()
+	ls.scheduler.After(interval, func() { ls.generate(run) })
+}
+
+func (ls *LoadService) newReservation() {
+	ls.mutex.Lock()
+
+	var lv *loadVehicle = nil
+	for _, value := range ls.vehicles {
+		if !value.Busy {
+			lv = value
+			break
+		}
+	}
+
+	if lv == nil {
+		ls.stats.Skipped++
+		ls.mutex.Unlock()
+		return
+	}
+
+	lv.Busy = true
+	ls.stats.Reservations++
+	ls.lastReservation++
+	id := fmt.Sprintf("LOAD-%d", ls.lastReservation)
+	o := ls.options
+	scenario := o.NextScenario(ls.env.Random.Derive("load", id))
+	ls.mutex.Unlock()
+
+	now := ls.clock.Now()
+
+	r := new(domain.Reservation)
+	r.ReservationId = id
+	r.VehicleDevice = lv.VehicleDevice
+	r.AccessDevice = lv.AccessDevice
+	r.Timezone = ls.env.Settings.DefaultTimezone
+	r.StartTime = now.Add(-time.Minute)
+	r.EndTime = now.Add(o.TripDuration)
+	r.LateAlarm = true
+	r.LateBuffer = 5
+
+	if err := ls.reservationService.HandleNewReservation(r); err != nil {
+		fmt.Println("ERROR:", err)
+		ls.release(lv)
+		return
+	}
+
+	ls.drive(lv, r, scenario)
+}
+
+// drive plays the driver of one reservation, segments and trip data are generated by the simulator itself
+func (ls *LoadService) drive(lv *loadVehicle, r *domain.Reservation, scenario domain.LoadScenario) {
+	release := r.EndTime.Add(time.Duration(r.LateBuffer+5) * time.Minute)
+
+	if scenario == domain.NO_SHOW {
+		ls.count(func(s *domain.LoadStats) { s.NoShows++ })
+		ls.scheduler.For(lv.VehicleDevice).At(release, func() { ls.release(lv) })
+		return
+	}
+
+	returned := r.EndTime.Add(-2 * time.Minute)
+	if scenario == domain.LATE_RETURN {
+		returned = r.EndTime.Add(time.Duration(r.LateBuffer+2) * time.Minute)
+	}
+
+	ls.scheduler.For(lv.VehicleDevice).After(time.Minute, func() {
+		ls.swipe(lv)
+		ls.count(func(s *domain.LoadStats) {
+			s.Trips++
+			if scenario == domain.LATE_RETURN {
+				s.LateReturns++
+			}
+		})
+	})
+	ls.scheduler.For(lv.VehicleDevice).At(returned, func() { ls.swipe(lv) })
+	ls.scheduler.For(lv.VehicleDevice).At(release, func() { ls.release(lv) })
+}
+
+func (ls *LoadService) swipe(lv *loadVehicle) {
+	ds := new(domain.DriverSwipe)
+	ds.VehicleDevice = lv.VehicleDevice
+	ds.AccessDevice = lv.AccessDevice.GetVirtualAccessDevice()
+
+	if err := ls.reservationService.HandleNewDriverSwipe(ds); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+}
+
+func (ls *LoadService) count(f func(*domain.LoadStats)) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	f(ls.stats)
+}
+
+func (ls *LoadService) release(lv *loadVehicle) {
+	ls.mutex.Lock()
+	defer ls.mutex.Unlock()
+
+	lv.Busy = false
+}
+
+func (ls *LoadService) report(run int) {
+	ls.mutex.Lock()
+	running := ls.current(run)
+	s := ls.stats
+	line := fmt.Sprintf("LOAD: reservations=%d trips=%d late=%d noShows=%d skipped=%d messages=%d errors=%d (%.1f%%) avg=%.1fms max=%.1fms",
+		s.Reservations, s.Trips, s.LateReturns, s.NoShows, s.Skipped,
+		s.Total.Count, s.Total.Errors, s.Total.ErrorRate*100, s.Total.AvgResponseMs, s.Total.MaxResponseMs)
+	ls.mutex.Unlock()
+
+	if !running {
+		return
+	}
+
+	fmt.Println(line, "scheduled="+fmt.Sprint(ls.scheduler.Len()))
+	ls.scheduler.After(10*time.Second, func() { ls.report(run) })
+}
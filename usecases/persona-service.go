@@ -0,0 +1,315 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+	"time"
+)
+
+// PersonaService drives reservations without manual swipes, the persona of a
+// reservation is looked up by reservation, then by card, then the default
+type PersonaService struct {
+	reservationService *ReservationService
+	tripService        *TripService
+	env                *domain.Environment
+	clock              domain.Clock
+	scheduler          *Scheduler
+
+	mutex       sync.Mutex
+	personas    map[string]*domain.Persona
+	assignments domain.PersonaAssignments
+	scheduled   map[string]time.Time //start the persona of a reservation waits for
+	played      map[string]string    //persona that drove a reservation
+	silent      map[string]bool      //reservations driven without late alarm
+}
+
+func NewPersonaService(rs *ReservationService, ts *TripService, env *domain.Environment, clock domain.Clock, scheduler *Scheduler, defaultPersona string) *PersonaService {
+	ps := new(PersonaService)
+
+	ps.reservationService = rs
+	ps.tripService = ts
+	ps.env = env
+	ps.clock = clock
+	ps.scheduler = scheduler
+	ps.personas = make(map[string]*domain.Persona)
+	ps.assignments = domain.PersonaAssignments{Default: defaultPersona, Reservations: make(map[string]string), Cards: make([]domain.CardAssignment, 0)}
+	ps.scheduled = make(map[string]time.Time)
+	ps.played = make(map[string]string)
+	ps.silent = make(map[string]bool)
+
+	for key, value := range domain.GetPersonas() {
+		ps.personas[key] = value
+	}
+
+	rs.OnNewReservation(ps.HandleNewReservation)
+	rs.OnLateAlarm(ps.lateAlarm)
+
+	return ps
+}
+
+func (ps *PersonaService) GetPersonas() map[string]*domain.Persona {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	personas := make(map[string]*domain.Persona)
+	for key, value := range ps.personas {
+		personas[key] = value
+	}
+
+	return personas
+}
+
+func (ps *PersonaService) GetPersona(name string) *domain.Persona {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	return ps.personas[name]
+}
+
+func (ps *PersonaService) UpdatePersona(p *domain.Persona) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	ps.personas[p.Name] = p
+
+	return nil
+}
+
+func (ps *PersonaService) GetAssignments() domain.PersonaAssignments {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	a := domain.PersonaAssignments{Default: ps.assignments.Default, Reservations: make(map[string]string), Cards: make([]domain.CardAssignment, len(ps.assignments.Cards))}
+	for key, value := range ps.assignments.Reservations {
+		a.Reservations[key] = value
+	}
+	copy(a.Cards, ps.assignments.Cards)
+
+	return a
+}
+
+// Assign sets the persona of a reservation or the default one when key is empty,
+// an empty persona removes the assignment
+func (ps *PersonaService) Assign(kind string, key string, persona string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if persona != "" && ps.personas[persona] == nil {
+		return fmt.Errorf("Unknown persona: %s", persona)
+	}
+
+	switch kind {
+	case "default":
+		ps.assignments.Default = persona
+	case "reservation":
+		if persona == "" {
+			delete(ps.assignments.Reservations, key)
+		} else {
+			ps.assignments.Reservations[key] = persona
+		}
+	default:
+		return fmt.Errorf("Unknown assignment: %s", kind)
+	}
+
+	return nil
+}
+
+// AssignCard sets the persona of the reservations of a card, an empty persona removes the assignment
+func (ps *PersonaService) AssignCard(a domain.AccessDevice, persona string) error {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	if persona != "" && ps.personas[persona] == nil {
+		return fmt.Errorf("Unknown persona: %s", persona)
+	}
+
+	cards := make([]domain.CardAssignment, 0, len(ps.assignments.Cards)+1)
+	for _, value := range ps.assignments.Cards {
+		if !value.Card.Matches(a) {
+			cards = append(cards, value)
+		}
+	}
+
+	if persona != "" {
+		cards = append(cards, domain.CardAssignment{Card: a.Normalise(), Persona: persona})
+	}
+	ps.assignments.Cards = cards
+
+	return nil
+}
+
+// HandleNewReservation looks the persona up at the reservation start so that it can still be assigned until then,
+// a reservation moved before its persona played waits for its new start
+func (ps *PersonaService) HandleNewReservation(r *domain.Reservation) {
+	ps.mutex.Lock()
+	scheduled, ok := ps.scheduled[r.ReservationId]
+	_, played := ps.played[r.ReservationId]
+	if played || ok && scheduled.Equal(r.StartTime) {
+		ps.mutex.Unlock()
+		return
+	}
+
+	start := r.StartTime
+	ps.scheduled[r.ReservationId] = start
+	ps.mutex.Unlock()
+
+	ps.scheduler.For(r.VehicleDevice).At(start, func() {
+		ps.mutex.Lock()
+		_, played := ps.played[r.ReservationId]
+		current := !played && ps.scheduled[r.ReservationId].Equal(start)
+		if current {
+			ps.played[r.ReservationId] = ps.getPersonaName(r)
+			delete(ps.scheduled, r.ReservationId)
+		}
+		p := ps.personas[ps.played[r.ReservationId]]
+		ps.mutex.Unlock()
+
+		if current && p != nil {
+			ps.play(r, p)
+		}
+	})
+}
+
+// GetState takes the personas, assignments and played reservations a restarted simulator resumes from
+func (ps *PersonaService) GetState() *domain.PersonaState {
+	s := &domain.PersonaState{Personas: ps.GetPersonas(), Assignments: ps.GetAssignments(), Played: make(map[string]string)}
+
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	for key, value := range ps.played {
+		s.Played[key] = value
+	}
+
+	return s
+}
+
+// Resume restores a saved persona state, the trips in progress get the steps left of their persona
+// and the reservations not played yet wait for their start again
+func (ps *PersonaService) Resume(s *domain.PersonaState) {
+	ps.mutex.Lock()
+	if s != nil {
+		for key, value := range s.Personas {
+			ps.personas[key] = value
+		}
+		if s.Assignments.Reservations != nil {
+			ps.assignments.Reservations = s.Assignments.Reservations
+		}
+		if s.Assignments.Cards != nil {
+			ps.assignments.Cards = s.Assignments.Cards
+		}
+		ps.assignments.Default = s.Assignments.Default
+		for key, value := range s.Played {
+			ps.played[key] = value
+		}
+	}
+	ps.mutex.Unlock()
+
+	for _, r := range ps.reservationService.GetReservations() {
+		ps.mutex.Lock()
+		name, played := ps.played[r.ReservationId]
+		p := ps.personas[name]
+		ps.mutex.Unlock()
+
+		if played && p != nil {
+			ps.play(r, p)
+		} else if !played && len(r.Trips) == 0 && r.EndTime.After(ps.clock.Now()) {
+			//reservations already driven or over before a state kept the personas are left alone
+			ps.HandleNewReservation(r)
+		}
+	}
+}
+
+// getPersonaName looks the persona of a reservation up, the caller holds the mutex
+func (ps *PersonaService) getPersonaName(r *domain.Reservation) string {
+	name := ps.assignments.Reservations[r.ReservationId]
+	for i := 0; name == "" && i < len(ps.assignments.Cards); i++ {
+		if ps.assignments.Cards[i].Card.Matches(r.AccessDevice) {
+			name = ps.assignments.Cards[i].Persona
+		}
+	}
+	if name == "" {
+		name = ps.assignments.Default
+	}
+
+	return name
+}
+
+func (ps *PersonaService) play(r *domain.Reservation, p *domain.Persona) {
+	fmt.Println("Persona", p.Name, "drives reservation", r.ReservationId)
+
+	if p.DisableLateAlarm {
+		ps.mutex.Lock()
+		ps.silent[r.ReservationId] = true
+		ps.mutex.Unlock()
+	}
+
+	//a resumed reservation only gets the steps left of its trip
+	t := r.GetCurrentTrip()
+	started := t != nil && (t.Status == domain.IN_PROGRESS || t.Status == domain.LATE)
+	if p.NoShow || t != nil && !started {
+		return
+	}
+
+	now := ps.clock.Now()
+	if started {
+		now = r.StartTime
+	}
+	start, end := p.GetSwipeTimes(ps.env.Random, r, now)
+
+	if !started {
+		ps.scheduler.For(r.VehicleDevice).At(start, func() { ps.swipe(r) })
+	}
+
+	//each stop switches the ignition twice, two minutes apart
+	for i := 1; i <= p.Stops; i++ {
+		stop := start.Add(end.Sub(start) * time.Duration(i) / time.Duration(p.Stops+1))
+		if started && stop.Before(ps.clock.Now()) {
+			continue
+		}
+
+		ps.scheduler.For(r.VehicleDevice).At(stop, func() { ps.toggleIgnition(r) })
+		ps.scheduler.For(r.VehicleDevice).At(stop.Add(2*time.Minute), func() { ps.toggleIgnition(r) })
+	}
+
+	ps.scheduler.For(r.VehicleDevice).At(end, func() {
+		if t := r.GetCurrentTrip(); t != nil && p.KeepDataFob {
+			t.KeepDataFob = true
+		}
+
+		ps.swipe(r)
+	})
+}
+
+func (ps *PersonaService) lateAlarm(r *domain.Reservation) bool {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+
+	return !ps.silent[r.ReservationId]
+}
+
+func (ps *PersonaService) toggleIgnition(r *domain.Reservation) {
+	if t := r.GetCurrentTrip(); t != nil && (t.Status == domain.IN_PROGRESS || t.Status == domain.LATE) {
+		ps.tripService.HandleTripSegment(t)
+	}
+}
+
+func (ps *PersonaService) swipe(r *domain.Reservation) {
+	ds := new(domain.DriverSwipe)
+	ds.VehicleDevice = r.VehicleDevice
+	ds.AccessDevice = r.AccessDevice.GetVirtualAccessDevice()
+
+	//the driver knows the PIN of the booking
+	if r.AccessDevice.PIN != "" {
+		ds.PINAttempts = []string{string(r.AccessDevice.PIN)}
+	}
+
+	if err := ps.reservationService.HandleNewDriverSwipe(ds); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+}
@@ -2,8 +2,8 @@ package usecases
 
 import (
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/leoride/tako-sim/domain"
+	"sync"
 	"time"
 )
 
@@ -14,165 +14,316 @@ type ReservationClientI interface {
 type ReservationService struct {
 	reservationClient ReservationClientI
 	tripService       *TripService
-	reservations      []*domain.Reservation
-
-	cucmRequests map[string]*domain.DriverSwipe
-}
-
-type ReservationWatcherThread struct {
-	TripService *TripService
-	Reservation *domain.Reservation
+	vehicleService    *VehicleService
+	tenantService     *TenantService
+	taskService       *TaskService
+	cardService       *CardService
+	env               *domain.Environment
+	clock             domain.Clock
+	scheduler         *Scheduler
+
+	mutex               sync.Mutex
+	reservations        []*domain.Reservation
+	reservationIndex    map[string]*domain.Reservation
+	vehicleReservations map[domain.VehicleDevice][]*domain.Reservation
+	cucmRequests        map[string]*domain.PendingCUCMRequest
+	expiredCUCMRequests []*domain.PendingCUCMRequest
+	cucmTimeout         time.Duration
+	conflictOptions     domain.ConflictOptions
+	lateOptions         domain.LateOptions
+
+	onNewReservation []func(*domain.Reservation)
+	onLateAlarm      []func(*domain.Reservation) bool
 }
 
-func NewReservationService(rc ReservationClientI, ts *TripService, reservations []*domain.Reservation) *ReservationService {
+func NewReservationService(rc ReservationClientI, ts *TripService, vs *VehicleService, tns *TenantService, tks *TaskService, cs *CardService, env *domain.Environment, clock domain.Clock, scheduler *Scheduler, reservations []*domain.Reservation) *ReservationService {
 	rs := new(ReservationService)
 
 	rs.reservationClient = rc
 	rs.tripService = ts
+	rs.vehicleService = vs
+	rs.tenantService = tns
+	rs.taskService = tks
+	rs.cardService = cs
+	rs.env = env
+	rs.clock = clock
+	rs.scheduler = scheduler
 	rs.reservations = reservations
-	rs.cucmRequests = make(map[string]*domain.DriverSwipe)
+	rs.reservationIndex = make(map[string]*domain.Reservation)
+	rs.vehicleReservations = make(map[domain.VehicleDevice][]*domain.Reservation)
+	rs.cucmRequests = make(map[string]*domain.PendingCUCMRequest)
+	rs.expiredCUCMRequests = make([]*domain.PendingCUCMRequest, 0)
+	rs.cucmTimeout = domain.DEFAULT_CUCM_TIMEOUT
+	rs.conflictOptions = domain.ConflictOptions{SwipeResolution: domain.SWIPE_LAST}
+
+	for _, value := range reservations {
+		rs.reservationIndex[value.ReservationId] = value
+		rs.vehicleReservations[value.VehicleDevice] = append(rs.vehicleReservations[value.VehicleDevice], value)
+	}
 
 	return rs
 }
 
+// OnNewReservation registers f to be called with every new or updated reservation
+func (rs *ReservationService) OnNewReservation(f func(*domain.Reservation)) {
+	rs.onNewReservation = append(rs.onNewReservation, f)
+}
+
+// OnLateAlarm registers f to be asked before a late alarm is sent, f returns false to keep the alarm of a
+// reservation silent without changing the reservation
+func (rs *ReservationService) OnLateAlarm(f func(*domain.Reservation) bool) {
+	rs.onLateAlarm = append(rs.onLateAlarm, f)
+}
+
+func (rs *ReservationService) lateAlarm(r *domain.Reservation) bool {
+	for _, f := range rs.onLateAlarm {
+		if !f(r) {
+			return false
+		}
+	}
+
+	return r.LateAlarm
+}
+
 func (rs *ReservationService) GetReservations() []*domain.Reservation {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
 	return rs.reservations
 }
 
 func (rs *ReservationService) GetReservation(id string) *domain.Reservation {
-	for _, value := range rs.reservations {
-		if value.ReservationId == id {
-			return value
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	return rs.reservationIndex[id]
+}
+
+// Resume rebuilds the timers of the reservations and CUCM requests restored from a saved state,
+// checks due while the simulator was down run right away
+func (rs *ReservationService) Resume(cucmRequests []*domain.PendingCUCMRequest) {
+	for _, r := range rs.GetReservations() {
+		for _, t := range r.Trips {
+			rs.tripService.ResumeTrip(t)
+		}
+
+		if t := r.GetCurrentTrip(); t == nil || t.Status != domain.COMPLETED {
+			rs.scheduleCheck(r, r.EndTime)
 		}
 	}
 
-	return nil
+	for _, value := range cucmRequests {
+		if value.State != domain.CUCM_PENDING {
+			continue
+		}
+
+		pcr := value
+		pcr.Swipe.Vehicle = rs.vehicleService.GetOrCreateVehicle(pcr.Swipe.VehicleDevice)
+
+		rs.mutex.Lock()
+		rs.cucmRequests[pcr.Guid] = pcr
+		rs.mutex.Unlock()
+
+		rs.scheduler.For(pcr.Swipe.VehicleDevice).At(pcr.ExpiryTime, func() { rs.expireCUCMRequest(pcr) })
+	}
 }
 
-func (rs *ReservationService) HandleNewReservation(r *domain.Reservation) {
+func (rs *ReservationService) HandleNewReservation(r *domain.Reservation) error {
+	r.TechStatus = domain.NEW
+
+	if rejected, err := rs.checkFeature(domain.RESERVATION_TASK, r, domain.RESERVATIONS, func() { rs.sendReservationStatusUpdates(r) }); rejected {
+		return err
+	}
+
 	if r.AccessDevice.SmartcardSerialNo == "" {
 		r.AccessDevice.SmartcardSerialNo = "0"
 	}
+	r.AccessDevice = r.AccessDevice.Normalise()
 
-	r.GenerateTaskNumber()
-	r.TechStatus = domain.NEW
+	//a missing timezone reads as code 0, which is GMT-12 and not what the booking meant
+	if r.Timezone == 0 {
+		r.Timezone = rs.env.Settings.DefaultTimezone
+	}
 
-	var existingRes *domain.Reservation = nil
-	for _, value := range rs.reservations {
-		if value.ReservationId == r.ReservationId {
-			existingRes = value
-		}
+	rs.taskService.NewTask(domain.RESERVATION_TASK, r)
+
+	rs.vehicleService.GetOrCreateVehicle(r.VehicleDevice)
+
+	rs.mutex.Lock()
+	existingRes := rs.reservationIndex[r.ReservationId]
+	changes := make([]domain.ReservationChange, 0)
+
+	if conflict := rs.findConflict(r); conflict != nil {
+		rs.mutex.Unlock()
+		fmt.Println("Reservation", r.ReservationId, "rejected, it overlaps reservation", conflict.ReservationId)
+
+		r.TaskError = domain.RESERVATION_CONFLICT
+		rs.sendReservationStatusUpdates(r)
+
+		return nil
 	}
 
 	if existingRes != nil {
-		t := existingRes.Trip
-		*existingRes = *r
-		existingRes.Trip = t
+		rs.unindexVehicle(existingRes)
+		changes = existingRes.Update(r, rs.clock.Now())
 		fmt.Println("Existing reservation updated:")
 	} else {
+		existingRes = r
 		rs.reservations = append(rs.reservations, r)
+		rs.reservationIndex[r.ReservationId] = r
 		fmt.Println("New reservation received:")
+	}
 
-		rw := new(ReservationWatcherThread)
-		rw.TripService = rs.tripService
-		rw.Reservation = r
-		go rw.Watch()
+	rs.vehicleReservations[existingRes.VehicleDevice] = append(rs.vehicleReservations[existingRes.VehicleDevice], existingRes)
+	rs.mutex.Unlock()
+
+	for _, value := range changes {
+		fmt.Println("Reservation", existingRes.ReservationId, "changed", value.Field, "from", value.Old, "to", value.New)
 	}
 
-	fmt.Println(r)
+	//a trip reported late is on time again when the booking is extended, the late alarm is pushed back
+	if t := existingRes.GetCurrentTrip(); t != nil && t.Status == domain.LATE && rs.clock.Now().Before(existingRes.GetLateTime()) {
+		fmt.Println("Reservation", existingRes.ReservationId, "extended, trip", t.TripNo, "is no longer late")
+		t.Status = domain.IN_PROGRESS
+		t.LateAlarms = 0
+	}
 
-	go rs.sendReservationStatusUpdates(r)
-}
+	//checks scheduled for an earlier revision of the reservation do nothing
+	if existingRes == r || len(changes) > 0 {
+		rs.scheduleCheck(existingRes, existingRes.EndTime)
+	}
+
+	fmt.Println(existingRes)
 
-func (rs *ReservationService) HandleNewDriverSwipe(ds *domain.DriverSwipe) {
-	if ds.AccessDevice.SmartcardType == "Hitag32" {
-		ds.AccessDevice.SmartcardType = "Hitag_32"
-	} else if ds.AccessDevice.SmartcardType == "Hitag16" {
-		ds.AccessDevice.SmartcardType = "Hitag_16"
+	for _, f := range rs.onNewReservation {
+		f(existingRes)
 	}
 
-	ds.GenerateTaskNumber()
+	rs.sendReservationStatusUpdates(r)
+
+	return nil
+}
+
+func (rs *ReservationService) HandleNewDriverSwipe(ds *domain.DriverSwipe) error {
 	ds.TechStatus = domain.NEW
 
-	var existingRes *domain.Reservation = nil
-	for _, value := range rs.reservations {
-
-		if value.VehicleDevice.OrgaNo == ds.VehicleDevice.OrgaNo &&
-			value.VehicleDevice.VehiclePhoneNo == ds.VehicleDevice.VehiclePhoneNo {
-
-			if value.StartTime.Before(time.Now()) &&
-				(time.Now().Before(value.EndTime) || value.Trip != nil && (value.Trip.Status == domain.IN_PROGRESS || value.Trip.Status == domain.LATE)) {
-
-				if value.AccessDevice.SmartcardType == ds.AccessDevice.SmartcardType {
-					switch value.AccessDevice.SmartcardType {
-					case "Hitag_16":
-					case "Hitag_32":
-						if value.AccessDevice.SmartcardCardNo == ds.AccessDevice.SmartcardCardNo &&
-							value.AccessDevice.SmartcardOrgaNo == ds.AccessDevice.SmartcardOrgaNo {
-							existingRes = value
-						}
-						break
-					default:
-						if value.AccessDevice.SmartcardSerialNo == ds.AccessDevice.SmartcardSerialNo {
-							existingRes = value
-						}
-						break
-					}
-				}
+	if rejected, err := rs.checkFeature(domain.DRIVER_SWIPE_TASK, ds, domain.VIRTUAL_CARDS, func() { rs.sendDriverSwipeStatusUpdates(ds) }); rejected {
+		return err
+	}
+
+	ds.AccessDevice.SmartcardType = domain.NormaliseCardType(ds.AccessDevice.SmartcardType)
+	ds.Vehicle = rs.vehicleService.GetOrCreateVehicle(ds.VehicleDevice)
+
+	rs.taskService.NewTask(domain.DRIVER_SWIPE_TASK, ds)
+
+	rs.continueSwipe(ds)
+	rs.sendDriverSwipeStatusUpdates(ds)
+
+	return nil
+}
+
+// continueSwipe checks the access of a swipe and starts or ends the trip of its reservation,
+// a swipe answered by CUCM continues here and keeps its task
+func (rs *ReservationService) continueSwipe(ds *domain.DriverSwipe) {
+	if reason := rs.cardService.CheckAccess(ds.AccessDevice.GetAccessDevice(), ds.VehicleDevice, rs.clock.Now()); reason != "" {
+		fmt.Println("Driver swipe received, but card rejected:", reason)
+
+		ds.RejectionReason = reason
+		rs.tripService.HandleRejectedAccess(ds)
+
+		return
+	}
+
+	rs.mutex.Lock()
+	reservations := rs.vehicleReservations[ds.VehicleDevice]
+	rs.mutex.Unlock()
+
+	candidates := make([]*domain.Reservation, 0)
+	for _, value := range reservations {
+		t := value.GetCurrentTrip()
+
+		if value.StartTime.Before(rs.clock.Now()) &&
+			(rs.clock.Now().Before(value.EndTime) || t != nil && (t.Status == domain.IN_PROGRESS || t.Status == domain.LATE)) {
+
+			if value.AccessDevice.Matches(ds.AccessDevice.GetAccessDevice()) {
+				candidates = append(candidates, value)
 			}
 		}
 	}
 
-	if existingRes == nil {
+	existingRes := rs.GetConflictOptions().SwipeResolution.ResolveSwipe(candidates)
+
+	if existingRes == nil && len(candidates) > 0 {
+		fmt.Println("Driver swipe received, but", len(candidates), "reservations match")
+
+		ds.RejectionReason = domain.AMBIGUOUS
+		rs.tripService.HandleRejectedAccess(ds)
+
+	} else if existingRes == nil && rs.tenantService.CheckFeature(ds.GetOrgaNo(), domain.CUCM) != nil {
+		fmt.Println("Driver swipe received, but no reservation found and CUCM disabled")
+
+		ds.RejectionReason = domain.NO_RESERVATION
+		rs.tripService.HandleRejectedAccess(ds)
+
+	} else if existingRes == nil {
 		fmt.Println("Driver swipe received, but no reservation found")
 
-		ds.CUCMGuid = uuid.New().String()
-		rs.cucmRequests[ds.CUCMGuid] = ds
+		ds.CUCMGuid = rs.env.Random.Derive("cucm", ds.VehicleDevice.OrgaNo, ds.VehicleDevice.VehiclePhoneNo, ds.RequestId, rs.clock.Now().UnixNano()).NewGuid()
+		rs.addCUCMRequest(ds)
 		rs.tripService.HandleCUCMRequest(ds)
 
-	} else if existingRes != nil && existingRes.Trip == nil {
-		fmt.Println("Driver swipe received, starting trip for reservation", existingRes.ReservationId)
+	} else if t := existingRes.GetCurrentTrip(); (t == nil || t.Status == domain.ENDED) && !rs.checkPIN(ds, existingRes) {
+		fmt.Println("Driver swipe received, but PIN check failed:", ds.PINResult, "after", ds.PINTries, "tries")
 
-		trip := new(domain.Trip)
-		trip.VehicleDevice = existingRes.VehicleDevice
-		trip.AccessDevice = existingRes.AccessDevice
-		trip.ReservationId = existingRes.ReservationId
-		trip.Reservation = existingRes
+		rs.tripService.HandleRejectedAccess(ds)
 
-		trip.IgnitionStatus = true
-		trip.IgnitionChange = time.Now()
+	} else if t == nil || t.Status == domain.ENDED {
+		trip := existingRes.NewTrip()
+		fmt.Println("Driver swipe received, starting trip", trip.TripNo, "for reservation", existingRes.ReservationId)
 
-		existingRes.Trip = trip
+		trip.PINTries = ds.PINTries
+		trip.IgnitionStatus = true
+		trip.IgnitionChange = rs.clock.Now()
 
 		rs.tripService.HandleTripStart(trip)
 
-	} else if existingRes != nil && existingRes.Trip != nil {
-		if existingRes.Trip.Status == domain.ENDED {
-			fmt.Println("Driver swipe received for ongoing trip, starting trip again", existingRes.ReservationId)
-
-			existingRes.Trip.IgnitionStatus = true
-			existingRes.Trip.IgnitionChange = time.Now()
-			rs.tripService.HandleTripStart(existingRes.Trip)
+	} else {
+		fmt.Println("Driver swipe received for ongoing trip, ending trip", t.TripNo, "for reservation", existingRes.ReservationId)
+		rs.tripService.HandleTripEnd(t)
 
-		} else {
-			fmt.Println("Driver swipe received for ongoing trip, ending trip", existingRes.ReservationId)
-			rs.tripService.HandleTripEnd(existingRes.Trip)
+		//returned after the reservation end, the trip is completed right away
+		if !rs.clock.Now().Before(existingRes.EndTime) {
+			rs.scheduleCheck(existingRes, rs.clock.Now().Add(time.Second))
 		}
 	}
-
-	go rs.sendDriverSwipeStatusUpdates(ds)
 }
 
-func (rs *ReservationService) HandleNewCUCMResponse(cr *domain.CUCMResponse) {
-	cr.GenerateTaskNumber()
+func (rs *ReservationService) HandleNewCUCMResponse(cr *domain.CUCMResponse) error {
 	cr.TechStatus = domain.NEW
 
-	ds := rs.cucmRequests[cr.Guid]
+	if rejected, err := rs.checkFeature(domain.CUCM_RESPONSE_TASK, cr, domain.CUCM, func() { rs.sendCUCMResponseStatusUpdates(cr) }); rejected {
+		return err
+	}
+
+	if cr.Timezone == 0 {
+		cr.Timezone = rs.env.Settings.DefaultTimezone
+	}
+
+	ds, err := rs.answerCUCMRequest(cr.Guid)
+
+	if err != nil {
+		return err
+	}
+
+	task := rs.taskService.NewTask(domain.CUCM_RESPONSE_TASK, cr)
 
 	if ds != nil {
+		rs.taskService.Relate(task, cr.ReservationId, ds.RequestId)
+
 		if cr.ReservationId == "" {
 			fmt.Println("This is a refusal - Generate Rejected Access!")
 
+			ds.RejectionReason = domain.NO_RESERVATION
 			rs.tripService.HandleRejectedAccess(ds)
 		} else {
 			fmt.Println("This is a success - Create reservation and Generate Trip Start!")
@@ -188,86 +339,323 @@ func (rs *ReservationService) HandleNewCUCMResponse(cr *domain.CUCMResponse) {
 			r.Timezone = cr.Timezone
 			r.VehicleDevice = cr.VehicleDevice
 
-			rs.HandleNewReservation(r)
-			rs.HandleNewDriverSwipe(ds)
+			if err := rs.HandleNewReservation(r); err != nil {
+				return err
+			}
+
+			rs.continueSwipe(ds)
 		}
 	}
 
-	go rs.sendCUCMResponseStatusUpdates(cr)
+	rs.sendCUCMResponseStatusUpdates(cr)
+
+	return nil
+}
+
+func (rs *ReservationService) GetCUCMRequests() domain.CUCMRequests {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	crs := domain.CUCMRequests{Timeout: rs.cucmTimeout.String(), Pending: make([]*domain.PendingCUCMRequest, 0)}
+	for _, value := range rs.cucmRequests {
+		crs.Pending = append(crs.Pending, value)
+	}
+	crs.Expired = append(crs.Expired, rs.expiredCUCMRequests...)
+
+	return crs
+}
+
+// SetCUCMTimeout sets how long Tako may take to answer a CUCM request, pending requests keep their expiry
+func (rs *ReservationService) SetCUCMTimeout(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("CUCM timeout must be positive: %v", d)
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.cucmTimeout = d
+
+	return nil
+}
+
+func (rs *ReservationService) GetConflictOptions() domain.ConflictOptions {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	return rs.conflictOptions
+}
+
+func (rs *ReservationService) SetConflictOptions(o domain.ConflictOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.conflictOptions = o
+
+	return nil
+}
+
+func (rs *ReservationService) GetLateOptions() domain.LateOptions {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	return rs.lateOptions
+}
+
+func (rs *ReservationService) SetLateOptions(o domain.LateOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.lateOptions = o
+
+	return nil
+}
+
+func (rs *ReservationService) HandleNewCommand(c *domain.Command) error {
+	c.TechStatus = domain.NEW
+
+	//a rejected command is never executed
+	if rejected, err := rs.checkFeature(domain.COMMAND_TASK, c, domain.COMMANDS, func() {
+		rs.sendStatusUpdates(c, func(s domain.TaskStatus) { c.TechStatus = s }, nil)
+	}); rejected {
+		return err
+	}
+
+	rs.taskService.NewTask(domain.COMMAND_TASK, c)
+	c.Vehicle = rs.vehicleService.GetOrCreateVehicle(c.VehicleDevice)
+
+	fmt.Println("New command received:")
+	fmt.Println(c)
+
+	rs.sendCommandStatusUpdates(c)
+
+	return nil
+}
+
+// checkFeature rejects a request of a tenant without the feature with a TaskError in its status updates,
+// like a reservation conflict. Requests of unknown orgas have no Tako to answer to and fail.
+func (rs *ReservationService) checkFeature(tt domain.TaskType, r domain.RequestI, f domain.Feature, sendStatusUpdates func()) (bool, error) {
+	err := rs.tenantService.CheckFeature(r.GetOrgaNo(), f)
+
+	if err == nil {
+		return false, nil
+	} else if rs.tenantService.GetTenant(r.GetOrgaNo()) == nil {
+		return true, err
+	}
+
+	fmt.Println("Task rejected:", err)
+
+	r.SetTaskError(domain.FEATURE_NOT_ENABLED)
+	rs.taskService.NewTask(tt, r)
+	sendStatusUpdates()
+
+	return true, nil
 }
 
 func (rs *ReservationService) sendReservationStatusUpdates(r *domain.Reservation) {
-	time.Sleep(time.Second * 5)
-	r.TechStatus = domain.SENT_TO_CUCM
-	rs.reservationClient.SendUpdate(r)
+	rs.sendStatusUpdates(r, func(s domain.TaskStatus) { r.TechStatus = s }, nil)
+}
 
-	time.Sleep(time.Second * 5)
-	r.TechStatus = domain.ACCEPTED_BY_CUCM
-	rs.reservationClient.SendUpdate(r)
+func (rs *ReservationService) sendDriverSwipeStatusUpdates(ds *domain.DriverSwipe) {
+	rs.sendStatusUpdates(ds, func(s domain.TaskStatus) { ds.TechStatus = s }, nil)
+}
+
+func (rs *ReservationService) sendCUCMResponseStatusUpdates(cr *domain.CUCMResponse) {
+	rs.sendStatusUpdates(cr, func(s domain.TaskStatus) { cr.TechStatus = s }, nil)
+}
+
+func (rs *ReservationService) sendCommandStatusUpdates(c *domain.Command) {
+	rs.sendStatusUpdates(c, func(s domain.TaskStatus) { c.TechStatus = s }, func() { rs.tripService.HandleCommand(c) })
+}
 
-	time.Sleep(time.Second * 5)
-	r.TechStatus = domain.RECEIVED
+// sendStatusUpdates walks a task through SendToCUCM, AcceptedFromCUCM and Done,
+// accepted runs once the device accepted the task
+func (rs *ReservationService) sendStatusUpdates(r domain.RequestI, setStatus func(domain.TaskStatus), accepted func()) {
+	update := func(s domain.TaskStatus) func() {
+		return func() {
+			setStatus(s)
+			rs.sendUpdate(r)
+
+			if s == domain.ACCEPTED_BY_CUCM && accepted != nil {
+				accepted()
+			}
+		}
+	}
+
+	delay := rs.env.Settings.Timings.StatusUpdate
+
+	//the updates of a request are keyed by the request, it has no vehicle of its own
+	rs.scheduler.For(r).SendSequence(
+		Step{Delay: delay, Run: update(domain.SENT_TO_CUCM)},
+		Step{Delay: delay, Run: update(domain.ACCEPTED_BY_CUCM)},
+		Step{Delay: delay, Run: update(domain.RECEIVED)},
+	)
+}
+
+func (rs *ReservationService) sendUpdate(r domain.RequestI) {
+	rs.taskService.UpdateStatus(r)
 	rs.reservationClient.SendUpdate(r)
 }
 
-func (rs *ReservationService) sendDriverSwipeStatusUpdates(ds *domain.DriverSwipe) {
-	time.Sleep(time.Second * 5)
-	ds.TechStatus = domain.SENT_TO_CUCM
-	rs.reservationClient.SendUpdate(ds)
+// findConflict returns a reservation booking the vehicle of r at the same time, when overlaps are rejected
+func (rs *ReservationService) findConflict(r *domain.Reservation) *domain.Reservation {
+	if !rs.conflictOptions.RejectOverlaps {
+		return nil
+	}
+
+	for _, value := range rs.vehicleReservations[r.VehicleDevice] {
+		if value.Overlaps(r) {
+			return value
+		}
+	}
+
+	return nil
+}
 
-	time.Sleep(time.Second * 5)
-	ds.TechStatus = domain.ACCEPTED_BY_CUCM
-	rs.reservationClient.SendUpdate(ds)
+func (rs *ReservationService) addCUCMRequest(ds *domain.DriverSwipe) {
+	rs.mutex.Lock()
+	pcr := &domain.PendingCUCMRequest{
+		Guid:       ds.CUCMGuid,
+		Swipe:      ds,
+		State:      domain.CUCM_PENDING,
+		SentTime:   rs.clock.Now(),
+		ExpiryTime: rs.clock.Now().Add(rs.cucmTimeout),
+	}
+	rs.cucmRequests[ds.CUCMGuid] = pcr
+	rs.mutex.Unlock()
 
-	time.Sleep(time.Second * 5)
-	ds.TechStatus = domain.RECEIVED
-	rs.reservationClient.SendUpdate(ds)
+	rs.scheduler.For(pcr.Swipe.VehicleDevice).At(pcr.ExpiryTime, func() { rs.expireCUCMRequest(pcr) })
 }
 
-func (rs *ReservationService) sendCUCMResponseStatusUpdates(cr *domain.CUCMResponse) {
-	time.Sleep(time.Second * 5)
-	cr.TechStatus = domain.SENT_TO_CUCM
-	rs.reservationClient.SendUpdate(cr)
+// answerCUCMRequest takes the swipe of a pending CUCM request, answers to expired requests are rejected
+func (rs *ReservationService) answerCUCMRequest(guid string) (*domain.DriverSwipe, error) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	if pcr := rs.cucmRequests[guid]; pcr != nil {
+		pcr.State = domain.CUCM_ANSWERED
+		delete(rs.cucmRequests, guid)
+
+		return pcr.Swipe, nil
+	}
 
-	time.Sleep(time.Second * 5)
-	cr.TechStatus = domain.ACCEPTED_BY_CUCM
-	rs.reservationClient.SendUpdate(cr)
+	for _, value := range rs.expiredCUCMRequests {
+		if value.Guid == guid {
+			return nil, fmt.Errorf("CUCM request %s expired at %v", guid, value.ExpiryTime)
+		}
+	}
 
-	time.Sleep(time.Second * 5)
-	cr.TechStatus = domain.RECEIVED
-	rs.reservationClient.SendUpdate(cr)
+	fmt.Println("CUCM answer received for unknown request", guid)
+
+	return nil, nil
 }
 
-func (rw *ReservationWatcherThread) Watch() {
-	for {
-		r := rw.Reservation
-		t := r.Trip
+func (rs *ReservationService) expireCUCMRequest(pcr *domain.PendingCUCMRequest) {
+	rs.mutex.Lock()
+	if rs.cucmRequests[pcr.Guid] != pcr {
+		rs.mutex.Unlock()
+		return
+	}
 
-		if r.EndTime.Before(time.Now()) {
+	pcr.State = domain.CUCM_EXPIRED
+	delete(rs.cucmRequests, pcr.Guid)
 
-			if t != nil && t.Status == domain.ENDED {
+	rs.expiredCUCMRequests = append(rs.expiredCUCMRequests, pcr)
+	if len(rs.expiredCUCMRequests) > domain.CUCM_EXPIRED_HISTORY {
+		rs.expiredCUCMRequests = rs.expiredCUCMRequests[1:]
+	}
+	rs.mutex.Unlock()
 
-				rw.TripService.HandleTripComplete(t)
-				return
-			} else if t != nil &&
-				t.Status == domain.IN_PROGRESS &&
-				r.LateAlarm == true &&
-				time.Now().After(r.EndTime.Add(time.Minute*time.Duration(r.LateBuffer))) {
+	fmt.Println("CUCM request", pcr.Guid, "expired without answer - Generate Rejected Access!")
 
-				rw.TripService.HandleDriverLate(t)
-			} else if t == nil {
+	pcr.Swipe.RejectionReason = domain.CUCM_TIMEOUT
+	rs.tripService.HandleRejectedAccess(pcr.Swipe)
+}
 
-				rw.TripService.HandleNoDrive(r)
-			}
+// checkPIN plays the PIN attempts of a trip starting swipe and records the outcome in the swipe
+// checkPIN plays the PIN attempts of a swipe, swipes without attempts come from board computers
+// without PIN entry and are not checked
+func (rs *ReservationService) checkPIN(ds *domain.DriverSwipe, r *domain.Reservation) bool {
+	if len(ds.PINAttempts) == 0 {
+		return true
+	}
+
+	ds.PINResult, ds.PINTries = rs.cardService.CheckPIN(ds.AccessDevice.GetAccessDevice(), string(r.AccessDevice.PIN), ds.PINAttempts)
+
+	switch ds.PINResult {
+	case domain.PIN_WRONG:
+		ds.RejectionReason = domain.WRONG_PIN
+	case domain.PIN_BLOCKED:
+		ds.RejectionReason = domain.CARD_BLOCKED
+	}
+
+	return ds.PINResult == domain.PIN_OK
+}
+
+func (rs *ReservationService) unindexVehicle(r *domain.Reservation) {
+	reservations := rs.vehicleReservations[r.VehicleDevice]
+
+	for i, value := range reservations {
+		if value == r {
+			rs.vehicleReservations[r.VehicleDevice] = append(reservations[:i:i], reservations[i+1:]...)
+			return
 		}
+	}
+}
 
-		if t != nil &&
-			(t.Status == domain.IN_PROGRESS || t.Status == domain.LATE) &&
-			t.IgnitionChange.Before(time.Now().Add(time.Duration(-5)*time.Minute)) {
+func (rs *ReservationService) scheduleCheck(r *domain.Reservation, at time.Time) {
+	rs.mutex.Lock()
+	revision := r.Revision
+	rs.mutex.Unlock()
 
-			rw.TripService.HandleTripSegment(t)
+	rs.scheduler.For(r.VehicleDevice).At(at, func() {
+		rs.mutex.Lock()
+		current := r.Revision == revision
+		rs.mutex.Unlock()
+
+		if current {
+			rs.checkReservation(r)
 		}
+	})
+}
+
+func (rs *ReservationService) checkReservation(r *domain.Reservation) {
+	now := rs.clock.Now()
 
-		time.Sleep(1 * time.Second)
+	if now.Before(r.EndTime) {
+		return
 	}
+
+	t := r.GetCurrentTrip()
+	lateTime := r.GetLateTime()
+
+	if t == nil {
+		rs.tripService.HandleNoDrive(r)
+		rs.scheduleCheck(r, now.Add(time.Second))
+	} else if t.Status == domain.ENDED {
+		rs.tripService.HandleTripComplete(t)
+	} else if (t.Status == domain.IN_PROGRESS || t.Status == domain.LATE) && rs.lateAlarm(r) {
+		lo := rs.GetLateOptions()
+
+		if now.Before(lateTime) {
+			rs.scheduleCheck(r, lateTime)
+		} else if t.Status == domain.IN_PROGRESS || t.LateAlarms <= lo.MaxReminders {
+			fmt.Println("Trip", t.TripNo, "of reservation", r.ReservationId, "is late, alarm", t.LateAlarms+1)
+			rs.tripService.HandleDriverLate(t)
+
+			if t.LateAlarms <= lo.MaxReminders {
+				rs.scheduleCheck(r, now.Add(lo.ReminderInterval))
+			}
+		}
+	}
+
+	//trips still running are completed by the check scheduled when they end
 }
@@ -0,0 +1,375 @@
+package usecases
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+	"time"
+)
+
+const (
+	SCHEDULER_WORKERS = 64
+
+	shutdownPoll = 10 * time.Millisecond
+)
+
+type Step struct {
+	Delay time.Duration
+	Run   func()
+}
+
+type job struct {
+	at       time.Time
+	seq      uint64
+	run      func()
+	outbound bool
+	key      interface{}
+}
+
+type jobQueue []*job
+
+func before(a *job, b *job) bool {
+	if a.at.Equal(b.at) {
+		return a.seq < b.seq
+	}
+
+	return a.at.Before(b.at)
+}
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool { return before(q[i], q[j]) }
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x interface{}) { *q = append(*q, x.(*job)) }
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	j := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+
+	return j
+}
+
+// Scheduler keeps every delayed action of the simulator in timer queues,
+// due jobs are handed to a fixed pool of workers instead of sleeping goroutines.
+// Outbound jobs deliver messages and are flushed at shutdown, the other ones are
+// timers rebuilt from the saved state when the simulator resumes.
+// Jobs of the same key never run at the same time, see For.
+type Scheduler struct {
+	clock domain.Clock
+
+	mutex    sync.Mutex
+	timers   jobQueue
+	outbound jobQueue
+	seq      uint64
+	running  int
+	stopped  bool
+	active   map[interface{}]bool
+	waiting  map[interface{}][]*job
+
+	wake chan struct{}
+	work chan *job
+	done chan struct{}
+	stop sync.Once
+}
+
+// Lane schedules the jobs of one key, they run one after the other in their order even when they are
+// due at the same time
+type Lane struct {
+	scheduler *Scheduler
+	key       interface{}
+}
+
+func NewScheduler(clock domain.Clock) *Scheduler {
+	s := new(Scheduler)
+
+	s.clock = clock
+	s.timers = make(jobQueue, 0)
+	s.outbound = make(jobQueue, 0)
+	s.active = make(map[interface{}]bool)
+	s.waiting = make(map[interface{}][]*job)
+	s.wake = make(chan struct{}, 1)
+	s.work = make(chan *job)
+	s.done = make(chan struct{})
+
+	for i := 0; i < SCHEDULER_WORKERS; i++ {
+		go s.worker()
+	}
+	go s.loop()
+
+	return s
+}
+
+// For returns the lane of a key, the simulator keys the jobs changing a trip or a vehicle by the vehicle
+func (s *Scheduler) For(key interface{}) *Lane {
+	return &Lane{scheduler: s, key: key}
+}
+
+func (s *Scheduler) At(t time.Time, f func()) {
+	s.at(t, f, false, nil)
+}
+
+func (s *Scheduler) After(d time.Duration, f func()) {
+	s.At(s.clock.Now().Add(d), f)
+}
+
+// Send delays an outbound message, it is sent right away when the simulator shuts down
+func (s *Scheduler) Send(d time.Duration, f func()) {
+	s.at(s.clock.Now().Add(d), f, true, nil)
+}
+
+// Sequence runs the steps one after the other, each delay counts from the end of the previous step
+func (s *Scheduler) Sequence(steps ...Step) {
+	s.sequence(false, nil, steps)
+}
+
+// SendSequence is a Sequence of outbound messages
+func (s *Scheduler) SendSequence(steps ...Step) {
+	s.sequence(true, nil, steps)
+}
+
+func (l *Lane) At(t time.Time, f func()) {
+	l.scheduler.at(t, f, false, l.key)
+}
+
+func (l *Lane) After(d time.Duration, f func()) {
+	l.At(l.scheduler.clock.Now().Add(d), f)
+}
+
+func (l *Lane) Send(d time.Duration, f func()) {
+	l.scheduler.at(l.scheduler.clock.Now().Add(d), f, true, l.key)
+}
+
+func (l *Lane) Sequence(steps ...Step) {
+	l.scheduler.sequence(false, l.key, steps)
+}
+
+func (l *Lane) SendSequence(steps ...Step) {
+	l.scheduler.sequence(true, l.key, steps)
+}
+
+func (s *Scheduler) sequence(outbound bool, key interface{}, steps []Step) {
+	if len(steps) == 0 {
+		return
+	}
+
+	s.at(s.clock.Now().Add(steps[0].Delay), func() {
+		if steps[0].Run != nil {
+			steps[0].Run()
+		}
+
+		s.sequence(outbound, key, steps[1:])
+	}, outbound, key)
+}
+
+func (s *Scheduler) at(t time.Time, f func(), outbound bool, key interface{}) {
+	s.mutex.Lock()
+	s.seq++
+	j := &job{at: t, seq: s.seq, run: f, outbound: outbound, key: key}
+	if outbound {
+		heap.Push(&s.outbound, j)
+	} else {
+		heap.Push(&s.timers, j)
+	}
+	first := s.next() == j
+	s.mutex.Unlock()
+
+	//only a new first job changes how long the loop has to wait
+	if first {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// next returns the first job of both queues
+func (s *Scheduler) next() *job {
+	if len(s.timers) == 0 && len(s.outbound) == 0 {
+		return nil
+	} else if len(s.timers) == 0 {
+		return s.outbound[0]
+	} else if len(s.outbound) == 0 || before(s.timers[0], s.outbound[0]) {
+		return s.timers[0]
+	}
+
+	return s.outbound[0]
+}
+
+func (s *Scheduler) pop(j *job) {
+	if j.outbound {
+		heap.Pop(&s.outbound)
+	} else {
+		heap.Pop(&s.timers)
+	}
+}
+
+func (s *Scheduler) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.timers) + len(s.outbound)
+}
+
+// Idle reports whether no job is running or due
+func (s *Scheduler) Idle() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	next := s.next()
+
+	return s.running == 0 && (next == nil || next.at.After(s.clock.Now()))
+}
+
+// Shutdown stops the timers, waits for the running jobs and then runs the pending outbound jobs
+// without their delay, including the ones they schedule. The timers are dropped.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.stopped = true
+	s.mutex.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+
+	for {
+		s.mutex.Lock()
+		running := s.running
+		s.mutex.Unlock()
+
+		if running == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d jobs still running at shutdown", running)
+		case <-ticker.C:
+		}
+	}
+
+	return s.Flush(ctx)
+}
+
+// Flush runs the pending outbound jobs of a stopped scheduler in order until none is left
+func (s *Scheduler) Flush(ctx context.Context) error {
+	for {
+		s.mutex.Lock()
+		if len(s.outbound) == 0 {
+			s.mutex.Unlock()
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			dropped := len(s.outbound)
+			s.mutex.Unlock()
+
+			return fmt.Errorf("%d outbound messages not sent before the shutdown deadline", dropped)
+		}
+
+		next := heap.Pop(&s.outbound).(*job)
+		s.mutex.Unlock()
+
+		next.run()
+	}
+}
+
+// Stop ends the timer loop and the workers once their running job is done, the pending jobs are dropped.
+// A scheduler flushed by Shutdown is stopped afterwards to release its goroutines.
+func (s *Scheduler) Stop() {
+	s.stop.Do(func() {
+		s.mutex.Lock()
+		s.stopped = true
+		s.mutex.Unlock()
+
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+
+		//the loop may still be handing a job to a worker, the channel is closed once it returned
+		<-s.done
+		close(s.work)
+	})
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+
+	for {
+		s.mutex.Lock()
+		if s.stopped {
+			s.mutex.Unlock()
+			return
+		}
+
+		now := s.clock.Now()
+		next := s.next()
+
+		if next != nil && !next.at.After(now) {
+			s.pop(next)
+			s.running++
+
+			//a job of a busy key waits for the worker running its key
+			if next.key != nil && s.active[next.key] {
+				s.waiting[next.key] = append(s.waiting[next.key], next)
+				s.mutex.Unlock()
+				continue
+			}
+
+			if next.key != nil {
+				s.active[next.key] = true
+			}
+			s.mutex.Unlock()
+
+			s.work <- next
+			continue
+		}
+
+		var timer <-chan time.Time = nil
+		if next != nil {
+			timer = s.clock.After(next.at.Sub(now))
+		}
+		s.mutex.Unlock()
+
+		select {
+		case <-timer:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *Scheduler) worker() {
+	for j := range s.work {
+		for j != nil {
+			j.run()
+
+			s.mutex.Lock()
+			s.running--
+
+			key := j.key
+			j = nil
+
+			if key != nil {
+				if waiting := s.waiting[key]; len(waiting) > 0 {
+					j = waiting[0]
+					waiting[0] = nil
+					s.waiting[key] = waiting[1:]
+				} else {
+					delete(s.active, key)
+					delete(s.waiting, key)
+				}
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
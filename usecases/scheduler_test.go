@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"context"
+	"github.com/leoride/tako-sim/infrastructure"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLaneRunsJobsOneAfterTheOther(t *testing.T) {
+	s := NewScheduler(infrastructure.NewSystemClock())
+	defer s.Stop()
+
+	var (
+		wg      sync.WaitGroup
+		running int32
+		order   []int
+	)
+
+	due := time.Now()
+	for i := 0; i < 100; i++ {
+		i := i
+		wg.Add(1)
+
+		s.For("vehicle").At(due, func() {
+			defer wg.Done()
+
+			if atomic.AddInt32(&running, 1) > 1 {
+				t.Error("two jobs of the same key run at the same time")
+			}
+			time.Sleep(100 * time.Microsecond)
+			order = append(order, i)
+			atomic.AddInt32(&running, -1)
+		})
+	}
+
+	wg.Wait()
+
+	for i, value := range order {
+		if value != i {
+			t.Fatalf("jobs ran in order %v", order)
+		}
+	}
+}
+
+func TestShutdownFlushesOutboundJobsInOrder(t *testing.T) {
+	s := NewScheduler(infrastructure.NewSystemClock())
+	defer s.Stop()
+
+	sent := make([]int, 0)
+	for _, value := range []int{3, 1, 2} {
+		i := value
+		s.For(i%2).Send(time.Duration(i)*time.Hour, func() { sent = append(sent, i) })
+	}
+	s.After(time.Minute, func() { t.Error("timer run at shutdown") })
+
+	//a flushed message may schedule the next one of its sequence
+	s.SendSequence(Step{Delay: 4 * time.Hour, Run: func() { sent = append(sent, 4) }}, Step{Delay: time.Hour, Run: func() { sent = append(sent, 5) }})
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{1, 2, 3, 4, 5}
+	if len(sent) != len(want) {
+		t.Fatalf("sent %v, want %v", sent, want)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Fatalf("sent %v, want %v", sent, want)
+		}
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	s := NewScheduler(infrastructure.NewSystemClock())
+	defer s.Stop()
+
+	s.Send(time.Hour, func() {})
+	s.Send(time.Hour, func() {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Shutdown(ctx); err == nil {
+		t.Fatal("no error for the messages left at the deadline")
+	}
+}
@@ -0,0 +1,168 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+	"time"
+)
+
+type SinkService struct {
+	reservationService *ReservationService
+	env                *domain.Environment
+	clock              domain.Clock
+	scheduler          *Scheduler
+
+	mutex       sync.Mutex
+	messages    []*domain.ReceivedMessage //ring buffer of the last maxMessages messages
+	oldest      int
+	maxMessages int
+	responses   map[string]*domain.SinkResponse
+	cucmAnswer  domain.CUCMAnswer
+	lastId      int
+}
+
+func NewSinkService(rs *ReservationService, env *domain.Environment, clock domain.Clock, scheduler *Scheduler, cucmAnswer domain.CUCMAnswer) *SinkService {
+	ss := new(SinkService)
+
+	ss.reservationService = rs
+	ss.env = env
+	ss.clock = clock
+	ss.scheduler = scheduler
+	ss.messages = make([]*domain.ReceivedMessage, 0)
+	ss.maxMessages = domain.DEFAULT_SINK_MESSAGES
+	ss.responses = make(map[string]*domain.SinkResponse)
+	ss.cucmAnswer = cucmAnswer
+
+	return ss
+}
+
+// HandleMessage stores a received message in place of the oldest one once the sink is full
+// and returns the response configured for its path
+func (ss *SinkService) HandleMessage(m *domain.ReceivedMessage) *domain.SinkResponse {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	ss.lastId++
+	m.Id = ss.lastId
+	m.Time = ss.clock.Now()
+
+	if len(ss.messages) < ss.maxMessages {
+		ss.messages = append(ss.messages, m)
+	} else {
+		ss.messages[ss.oldest] = m
+		ss.oldest = (ss.oldest + 1) % len(ss.messages)
+	}
+
+	if r := ss.responses[m.Path]; r != nil {
+		return r
+	}
+
+	return domain.NewSinkResponse()
+}
+
+func (ss *SinkService) HandleCUCMRequest(cr *domain.CUCMRequest) {
+	ss.mutex.Lock()
+	answer := ss.cucmAnswer
+	ss.mutex.Unlock()
+
+	if answer == domain.CUCM_ANSWER_NONE || cr.Guid == "" {
+		return
+	}
+
+	ss.scheduler.Send(2*time.Second, func() { ss.answerCUCMRequest(cr, answer) })
+}
+
+func (ss *SinkService) GetMessages(f *domain.MessageFilter) []*domain.ReceivedMessage {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	messages := make([]*domain.ReceivedMessage, 0)
+
+	for _, value := range ss.getMessages() {
+		if f.Matches(value) {
+			messages = append(messages, value)
+		}
+	}
+
+	return messages
+}
+
+// getMessages returns the stored messages oldest first, the caller holds the mutex
+func (ss *SinkService) getMessages() []*domain.ReceivedMessage {
+	return append(append([]*domain.ReceivedMessage(nil), ss.messages[ss.oldest:]...), ss.messages[:ss.oldest]...)
+}
+
+func (ss *SinkService) ClearMessages() {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	ss.messages = make([]*domain.ReceivedMessage, 0)
+	ss.oldest = 0
+}
+
+// SetMaxMessages sets how many received messages the sink keeps, the oldest ones beyond it are dropped
+func (ss *SinkService) SetMaxMessages(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("Sink message limit must be positive: %d", n)
+	}
+
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	messages := ss.getMessages()
+	if len(messages) > n {
+		messages = messages[len(messages)-n:]
+	}
+
+	ss.messages = messages
+	ss.oldest = 0
+	ss.maxMessages = n
+
+	return nil
+}
+
+func (ss *SinkService) GetResponses() map[string]*domain.SinkResponse {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	responses := make(map[string]*domain.SinkResponse)
+	for key, value := range ss.responses {
+		responses[key] = value
+	}
+
+	return responses
+}
+
+func (ss *SinkService) SetResponse(path string, r *domain.SinkResponse) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if r == nil {
+		delete(ss.responses, path)
+	} else {
+		ss.responses[path] = r
+	}
+}
+
+func (ss *SinkService) GetCUCMAnswer() domain.CUCMAnswer {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	return ss.cucmAnswer
+}
+
+func (ss *SinkService) SetCUCMAnswer(a domain.CUCMAnswer) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	ss.cucmAnswer = a
+}
+
+func (ss *SinkService) answerCUCMRequest(cr *domain.CUCMRequest, answer domain.CUCMAnswer) {
+	fmt.Println("Sink answering CUCM request", cr.Guid, "with", answer)
+
+	if err := ss.reservationService.HandleNewCUCMResponse(cr.NewCUCMResponse(ss.env, answer, ss.clock.Now())); err != nil {
+		fmt.Println("ERROR:", err)
+	}
+}
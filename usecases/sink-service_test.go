@@ -0,0 +1,49 @@
+package usecases
+
+import (
+	"github.com/leoride/tako-sim/domain"
+	"github.com/leoride/tako-sim/infrastructure"
+	"testing"
+)
+
+func TestSinkKeepsTheLastMessages(t *testing.T) {
+	ss := NewSinkService(nil, domain.DefaultEnvironment(1), infrastructure.NewSystemClock(), nil, domain.CUCM_ANSWER_NONE)
+	if err := ss.SetMaxMessages(3); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func() []int {
+		ids := make([]int, 0)
+		for _, value := range ss.GetMessages(&domain.MessageFilter{}) {
+			ids = append(ids, value.Id)
+		}
+
+		return ids
+	}
+
+	for i := 0; i < 5; i++ {
+		ss.HandleMessage(&domain.ReceivedMessage{Path: "event"})
+	}
+	if got := ids(); len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("messages %v, want 3 to 5", got)
+	}
+
+	//a lower limit drops the oldest ones
+	if err := ss.SetMaxMessages(2); err != nil {
+		t.Fatal(err)
+	}
+	ss.HandleMessage(&domain.ReceivedMessage{Path: "event"})
+	if got := ids(); len(got) != 2 || got[0] != 5 || got[1] != 6 {
+		t.Errorf("messages %v, want 5 and 6", got)
+	}
+
+	ss.ClearMessages()
+	ss.HandleMessage(&domain.ReceivedMessage{Path: "event"})
+	if got := ids(); len(got) != 1 || got[0] != 7 {
+		t.Errorf("messages %v after clearing, want 7", got)
+	}
+
+	if err := ss.SetMaxMessages(0); err == nil {
+		t.Error("limit 0 accepted")
+	}
+}
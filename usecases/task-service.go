@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+)
+
+type TaskService struct {
+	clock domain.Clock
+
+	mutex          sync.Mutex
+	tasks          []*domain.Task
+	taskIndex      map[string]*domain.Task
+	lastTaskNumber int
+}
+
+func NewTaskService(clock domain.Clock) *TaskService {
+	ts := new(TaskService)
+
+	ts.clock = clock
+	ts.tasks = make([]*domain.Task, 0)
+	ts.taskIndex = make(map[string]*domain.Task)
+
+	return ts
+}
+
+// Resume continues the task numbers after the last one of a saved state
+func (ts *TaskService) Resume(lastTaskNumber int) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if lastTaskNumber > ts.lastTaskNumber {
+		ts.lastTaskNumber = lastTaskNumber
+	}
+}
+
+func (ts *TaskService) GetLastTaskNumber() int {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	return ts.lastTaskNumber
+}
+
+func (ts *TaskService) GetTasks() []*domain.Task {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	return append([]*domain.Task(nil), ts.tasks...)
+}
+
+func (ts *TaskService) GetTask(taskNumber string) *domain.Task {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	return ts.taskIndex[taskNumber]
+}
+
+// NewTask issues the next task number to the request and records it with its current status
+func (ts *TaskService) NewTask(tt domain.TaskType, r domain.RequestI) *domain.Task {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.lastTaskNumber++
+	r.SetRequestId(fmt.Sprint(ts.lastTaskNumber))
+
+	t := domain.NewTask(r.GetRequestId(), tt, r, ts.clock.Now())
+	ts.tasks = append(ts.tasks, t)
+	ts.taskIndex[t.TaskNumber] = t
+
+	return t
+}
+
+// Relate records the reservation and the swipe task a task belongs to
+func (ts *TaskService) Relate(t *domain.Task, reservationId string, swipeTaskNumber string) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	t.ReservationId = reservationId
+	t.SwipeTaskNumber = swipeTaskNumber
+}
+
+func (ts *TaskService) UpdateStatus(r domain.RequestI) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if t := ts.taskIndex[r.GetRequestId()]; t != nil {
+		t.AddStatus(r.GetTechStatus(), ts.clock.Now())
+	}
+}
@@ -0,0 +1,63 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+)
+
+type TenantService struct {
+	defaultEndpoint string
+	defaultVersion  string
+	tenants         []*domain.Tenant
+}
+
+func NewTenantService(defaultEndpoint string, defaultVersion string, tenants []*domain.Tenant) *TenantService {
+	ts := new(TenantService)
+
+	ts.defaultEndpoint = defaultEndpoint
+	ts.defaultVersion = defaultVersion
+	ts.tenants = tenants
+
+	//tenants not read by LoadTenants keep working, an unknown feature stays disabled
+	for _, value := range tenants {
+		if err := value.Validate(); err != nil {
+			fmt.Println("ERROR:", err)
+		}
+	}
+
+	return ts
+}
+
+func (ts *TenantService) GetTenants() []*domain.Tenant {
+	return ts.tenants
+}
+
+func (ts *TenantService) GetTenant(orgaNo string) *domain.Tenant {
+	//without tenant configuration every orga is routed to the default endpoint
+	if len(ts.tenants) == 0 {
+		t := domain.NewTenant(orgaNo, ts.defaultEndpoint)
+		t.InterfaceVersion = ts.defaultVersion
+
+		return t
+	}
+
+	for _, value := range ts.tenants {
+		if value.OrgaNo == orgaNo {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func (ts *TenantService) CheckFeature(orgaNo string, f domain.Feature) error {
+	t := ts.GetTenant(orgaNo)
+
+	if t == nil {
+		return fmt.Errorf("Unknown orga: %s", orgaNo)
+	} else if !t.HasFeature(f) {
+		return fmt.Errorf("Feature %s is not enabled for orga %s", f, orgaNo)
+	}
+
+	return nil
+}
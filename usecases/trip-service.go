@@ -1,14 +1,17 @@
 package usecases
 
 import (
+	"fmt"
 	"github.com/leoride/tako-sim/domain"
-	"math/rand"
+	"sync"
 	"time"
 )
 
 type TripClientI interface {
 	SendTripStart(*domain.Trip)
 	SendDataFobAction(*domain.Trip, bool)
+	SendLockAction(*domain.Trip, bool)
+	SendDoorAction(*domain.Trip, bool)
 	SendFirstIgnition(*domain.Trip)
 	SendTripEnd(*domain.Trip)
 
@@ -19,146 +22,334 @@ type TripClientI interface {
 	SendRejectedAccess(*domain.DriverSwipe)
 	SendCUCMRequest(*domain.DriverSwipe)
 	SendDriverLate(*domain.Trip)
+	SendIllegalTrip(*domain.Trip)
+	SendEmergencyTrip(*domain.Trip)
+
+	SendCommandEvent(*domain.Command)
 }
 
 type TripService struct {
-	tripClient TripClientI
-	trips      []*domain.Trip
+	tripClient     TripClientI
+	vehicleService *VehicleService
+	env            *domain.Environment
+	clock          domain.Clock
+	scheduler      *Scheduler
+
+	mutex sync.Mutex
+	trips []*domain.Trip
 }
 
-func NewTripService(tc TripClientI, trips []*domain.Trip) *TripService {
+func NewTripService(tc TripClientI, vs *VehicleService, env *domain.Environment, clock domain.Clock, scheduler *Scheduler, trips []*domain.Trip) *TripService {
 	ts := new(TripService)
 
 	ts.tripClient = tc
+	ts.vehicleService = vs
+	ts.env = env
+	ts.clock = clock
+	ts.scheduler = scheduler
 	ts.trips = trips
 
 	return ts
 }
 
+// GetTrips returns the trips driven without reservation
+func (ts *TripService) GetTrips() []*domain.Trip {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	return append([]*domain.Trip(nil), ts.trips...)
+}
+
+// HandleTripWithoutReservation drives a trip of the given kind for d, it is completed right after its end
+func (ts *TripService) HandleTripWithoutReservation(kind domain.TripKind, vd domain.VehicleDevice, a domain.AccessDevice, emergencyReason string, d time.Duration) (*domain.Trip, error) {
+	if d <= 0 {
+		return nil, fmt.Errorf("Trip duration must be positive: %v", d)
+	}
+
+	t, err := domain.NewTripWithoutReservation(kind, vd, a, emergencyReason)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.mutex.Lock()
+	ts.trips = append(ts.trips, t)
+	ts.mutex.Unlock()
+
+	fmt.Println("Starting", kind, "trip without reservation on vehicle", vd.VehiclePhoneNo)
+
+	t.IgnitionStatus = true
+	t.IgnitionChange = ts.clock.Now()
+	ts.HandleTripStart(t)
+
+	if t.Illegal {
+		ts.sendProblemEvent(t, ts.tripClient.SendIllegalTrip)
+	} else if t.EmergencyTrip {
+		ts.sendProblemEvent(t, ts.tripClient.SendEmergencyTrip)
+	}
+
+	t.PlannedEndTime = t.IgnitionChange.Add(d)
+	ts.scheduleTripEnd(t)
+
+	return t, nil
+}
+
+// Resume rebuilds the timers of the trips without reservation restored from a saved state
+func (ts *TripService) Resume() {
+	for _, value := range ts.GetTrips() {
+		ts.ResumeTrip(value)
+
+		switch value.Status {
+		case domain.IN_PROGRESS, domain.LATE:
+			ts.scheduleTripEnd(value)
+		case domain.ENDED:
+			t := value
+			ts.scheduler.For(t.VehicleDevice).After(time.Second, func() { ts.HandleTripComplete(t) })
+		}
+	}
+}
+
+// ResumeTrip links a restored trip to its vehicle and toggles the ignition again while it is running
+func (ts *TripService) ResumeTrip(t *domain.Trip) {
+	t.Vehicle = ts.vehicleService.GetOrCreateVehicle(t.VehicleDevice)
+
+	if t.Status == domain.IN_PROGRESS || t.Status == domain.LATE {
+		ts.scheduleTripSegment(t)
+	}
+}
+
 func (ts *TripService) HandleTripStart(t *domain.Trip) {
-	if t.OdoStart == 0 {
-		t.StartTime = time.Now()
-		t.OdoStart = rand.Intn(100000)
+	if t.Vehicle == nil {
+		t.Vehicle = ts.vehicleService.GetOrCreateVehicle(t.VehicleDevice)
+	}
+
+	if t.StartTime.IsZero() {
+		t.StartTime = ts.clock.Now()
+		t.OdoStart = t.Vehicle.Odometer
 	}
 
 	t.Status = domain.IN_PROGRESS
+	t.Vehicle.IgnitionStatus = t.IgnitionStatus
 
-	go ts.sendTripStart(t)
+	ts.sendTripStart(t)
+	ts.scheduleTripSegment(t)
 }
 
 func (ts *TripService) HandleTripEnd(t *domain.Trip) {
-	t.EndTime = time.Now()
+	t.EndTime = ts.clock.Now()
 	//t.OdoEnd = t.OdoStart + int(math.Ceil(time.Since(t.StartTime).Hours()*float64(rand.Intn(100)+1)))
+	t.RecordDelay()
 	t.Status = domain.ENDED
 
 	if t.IgnitionStatus == true {
 		ts.HandleTripSegment(t)
 	}
 
-	go ts.sendTripEnd(t)
+	ts.sendTripEnd(t)
 }
 
 func (ts *TripService) HandleNoDrive(r *domain.Reservation) {
 
-	t := new(domain.Trip)
-	t.VehicleDevice = r.VehicleDevice
-	t.AccessDevice = r.AccessDevice
-	t.ReservationId = r.ReservationId
-	t.Reservation = r
-	t.OdoStart = 0
-	t.OdoEnd = 0
-	t.StartTime = time.Now()
+	t := r.NewTrip()
+	t.Vehicle = ts.vehicleService.GetOrCreateVehicle(r.VehicleDevice)
+	t.OdoStart = t.Vehicle.Odometer
+	t.OdoEnd = t.Vehicle.Odometer
+	t.StartTime = ts.clock.Now()
 	t.EndTime = t.StartTime
 	t.Status = domain.ENDED
 
-	r.Trip = t
-	go ts.sendTripData(t)
+	ts.sendTripData(t)
 }
 
 func (ts *TripService) HandleTripComplete(t *domain.Trip) {
-	t.Status = domain.COMPLETED
+	if t.Reservation == nil {
+		t.Status = domain.COMPLETED
+	} else {
+		for _, value := range t.Reservation.Trips {
+			value.Status = domain.COMPLETED
+		}
+	}
 
-	go ts.sendTripComplete(t)
+	ts.sendTripComplete(t)
 }
 
 func (ts *TripService) HandleTripSegment(t *domain.Trip) {
 	t.IgnitionStatus = !t.IgnitionStatus
-	t.IgnitionChange = time.Now()
+	t.IgnitionChange = ts.clock.Now()
 
 	if t.IgnitionStatus == false {
 		if t.OdoEnd == 0 {
 			t.OdoEnd = t.OdoStart
 		}
-		t.OdoEnd = t.OdoEnd + 3
+		odometer := ts.env.Settings.Odometer
+		t.OdoEnd = t.OdoEnd + odometer.SegmentDistance
+
+		t.Vehicle.Odometer = t.OdoEnd
+		if t.Vehicle.Fuel > odometer.SegmentFuel {
+			t.Vehicle.Fuel = t.Vehicle.Fuel - odometer.SegmentFuel
+		} else {
+			t.Vehicle.Fuel = 0
+		}
 	}
-	t.EndTime = time.Now()
+	t.Vehicle.IgnitionStatus = t.IgnitionStatus
+	t.EndTime = ts.clock.Now()
 
-	go ts.sendTripSegment(t)
+	ts.sendTripSegment(t)
 }
 
 func (ts *TripService) HandleDriverLate(t *domain.Trip) {
 	t.Status = domain.LATE
+	t.LateAlarms++
 
-	go ts.sendDriverLate(t)
+	ts.sendDriverLate(t)
 }
 
 func (ts *TripService) HandleRejectedAccess(ds *domain.DriverSwipe) {
-	go ts.sendRejectedAccess(ds)
+	ts.sendRejectedAccess(ds)
 }
 
 func (ts *TripService) HandleCUCMRequest(ds *domain.DriverSwipe) {
-	go ts.sendCUCMRequest(ds)
+	ts.sendCUCMRequest(ds)
+}
+
+func (ts *TripService) HandleCommand(c *domain.Command) {
+	switch c.Type {
+	case domain.UNLOCK:
+		c.Vehicle.Locked = false
+	case domain.LOCK:
+		c.Vehicle.Locked = true
+	case domain.ENABLE_IMMOBILIZER:
+		c.Vehicle.Immobilized = true
+	case domain.DISABLE_IMMOBILIZER:
+		c.Vehicle.Immobilized = false
+	}
+
+	ts.sendCommandEvent(c)
+}
+
+// scheduleTripSegment toggles the ignition every segment interval while the trip is running
+func (ts *TripService) scheduleTripSegment(t *domain.Trip) {
+	interval := ts.env.Settings.Timings.SegmentInterval
+
+	ts.scheduler.For(t.VehicleDevice).At(t.IgnitionChange.Add(interval), func() {
+		if t.Status != domain.IN_PROGRESS && t.Status != domain.LATE {
+			return
+		}
+
+		if !t.IgnitionChange.After(ts.clock.Now().Add(-interval)) {
+			ts.HandleTripSegment(t)
+		}
+
+		ts.scheduleTripSegment(t)
+	})
+}
+
+// scheduleTripEnd ends a trip without reservation at its planned end, it is completed right after
+func (ts *TripService) scheduleTripEnd(t *domain.Trip) {
+	ts.scheduler.For(t.VehicleDevice).At(t.PlannedEndTime, func() {
+		ts.HandleTripEnd(t)
+		ts.scheduler.For(t.VehicleDevice).After(time.Second, func() { ts.HandleTripComplete(t) })
+	})
 }
 
 func (ts *TripService) sendTripStart(t *domain.Trip) {
-	time.Sleep(time.Second * 30)
-	ts.tripClient.SendTripStart(t)
+	timings := ts.env.Settings.Timings
 
-	time.Sleep(time.Second * 10)
-	ts.tripClient.SendDataFobAction(t, true)
+	steps := []Step{{Delay: timings.TripStart, Run: func() { ts.tripClient.SendTripStart(t) }}}
 
-	if t.OdoEnd == 0 {
-		time.Sleep(time.Second * 10)
-		ts.tripClient.SendFirstIgnition(t)
+	//an illegal trip starts without card, the vehicle stays locked and the data fob in place
+	if !t.Vehicle.TripOptions.SkipUnlockAtStart && !t.Illegal {
+		steps = append(steps, Step{Delay: timings.Unlock, Run: func() {
+			t.Vehicle.Locked = false
+			ts.tripClient.SendLockAction(t, false)
+		}})
 	}
+
+	steps = append(steps,
+		Step{Delay: timings.DoorOpen, Run: func() {
+			t.Vehicle.DoorOpen = true
+			ts.tripClient.SendDoorAction(t, true)
+		}},
+		Step{Delay: timings.DoorClose, Run: func() {
+			t.Vehicle.DoorOpen = false
+			ts.tripClient.SendDoorAction(t, false)
+		}},
+		Step{Delay: timings.DataFobRemoved, Run: func() {
+			if !t.Illegal {
+				ts.tripClient.SendDataFobAction(t, true)
+			}
+		}},
+		Step{Delay: timings.FirstIgnition, Run: func() {
+			if t.OdoEnd == 0 {
+				ts.tripClient.SendFirstIgnition(t)
+			}
+		}})
+
+	ts.scheduler.For(t.VehicleDevice).SendSequence(steps...)
 }
 
 func (ts *TripService) sendTripEnd(t *domain.Trip) {
-	time.Sleep(time.Second * 30)
-	ts.tripClient.SendTripEnd(t)
+	timings := ts.env.Settings.Timings
+
+	steps := []Step{
+		{Delay: timings.TripEnd, Run: func() { ts.tripClient.SendTripEnd(t) }},
+		{Delay: timings.DataFobReturned, Run: func() {
+			if !t.KeepDataFob {
+				ts.tripClient.SendDataFobAction(t, false)
+			}
+		}},
+		{Delay: timings.ReturnDoorOpen, Run: func() {
+			t.Vehicle.DoorOpen = true
+			ts.tripClient.SendDoorAction(t, true)
+		}},
+	}
+
+	if !t.Vehicle.TripOptions.DoorLeftOpenAtReturn {
+		steps = append(steps, Step{Delay: timings.ReturnDoorClose, Run: func() {
+			t.Vehicle.DoorOpen = false
+			ts.tripClient.SendDoorAction(t, false)
+		}})
+
+		if !t.Vehicle.TripOptions.SkipLockAtReturn {
+			steps = append(steps, Step{Delay: timings.ReturnLock, Run: func() {
+				t.Vehicle.Locked = true
+				ts.tripClient.SendLockAction(t, true)
+			}})
+		}
+	}
 
-	time.Sleep(time.Second * 10)
-	ts.tripClient.SendDataFobAction(t, false)
+	steps = append(steps, Step{Delay: 0, Run: func() { ts.sendTripData(t) }})
 
-	go ts.sendTripData(t)
+	ts.scheduler.For(t.VehicleDevice).SendSequence(steps...)
 }
 
 func (ts *TripService) sendTripSegment(t *domain.Trip) {
-	time.Sleep(time.Second * 5)
-	ts.tripClient.SendTripSegment(t)
+	ts.scheduler.For(t.VehicleDevice).Send(ts.env.Settings.Timings.TripSegment, func() { ts.tripClient.SendTripSegment(t) })
 }
 
 func (ts *TripService) sendTripData(t *domain.Trip) {
-	time.Sleep(time.Second * 5)
-	ts.tripClient.SendTripData(t)
+	ts.scheduler.For(t.VehicleDevice).Send(ts.env.Settings.Timings.TripData, func() { ts.tripClient.SendTripData(t) })
 }
 
 func (ts *TripService) sendTripComplete(t *domain.Trip) {
-	time.Sleep(time.Second * 60)
-	ts.tripClient.SendTripComplete(t)
+	ts.scheduler.For(t.VehicleDevice).Send(ts.env.Settings.Timings.TripComplete, func() { ts.tripClient.SendTripComplete(t) })
 }
 
 func (ts *TripService) sendDriverLate(t *domain.Trip) {
-	time.Sleep(time.Second * 5)
-	ts.tripClient.SendDriverLate(t)
+	ts.scheduler.For(t.VehicleDevice).Send(ts.env.Settings.Timings.DriverLate, func() { ts.tripClient.SendDriverLate(t) })
+}
+
+func (ts *TripService) sendProblemEvent(t *domain.Trip, send func(*domain.Trip)) {
+	ts.scheduler.For(t.VehicleDevice).Send(ts.env.Settings.Timings.ProblemEvent, func() { send(t) })
 }
 
 func (ts *TripService) sendRejectedAccess(ds *domain.DriverSwipe) {
-	time.Sleep(time.Second * 30)
-	ts.tripClient.SendRejectedAccess(ds)
+	ts.scheduler.For(ds.VehicleDevice).Send(ts.env.Settings.Timings.RejectedAccess, func() { ts.tripClient.SendRejectedAccess(ds) })
 }
 
 func (ts *TripService) sendCUCMRequest(ds *domain.DriverSwipe) {
-	time.Sleep(time.Second * 30)
-	ts.tripClient.SendCUCMRequest(ds)
+	ts.scheduler.For(ds.VehicleDevice).Send(ts.env.Settings.Timings.CUCMRequest, func() { ts.tripClient.SendCUCMRequest(ds) })
+}
+
+func (ts *TripService) sendCommandEvent(c *domain.Command) {
+	ts.scheduler.For(c.VehicleDevice).Send(ts.env.Settings.Timings.CommandEvent, func() { ts.tripClient.SendCommandEvent(c) })
 }
@@ -0,0 +1,94 @@
+package usecases
+
+import (
+	"fmt"
+	"github.com/leoride/tako-sim/domain"
+	"sync"
+)
+
+type VehicleService struct {
+	env      *domain.Environment
+	mutex    sync.Mutex
+	vehicles []*domain.Vehicle
+}
+
+func NewVehicleService(env *domain.Environment, vehicles []*domain.Vehicle) *VehicleService {
+	vs := new(VehicleService)
+
+	vs.env = env
+	vs.vehicles = vehicles
+
+	return vs
+}
+
+func (vs *VehicleService) GetVehicles() []*domain.Vehicle {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	vehicles := make([]*domain.Vehicle, len(vs.vehicles))
+	copy(vehicles, vs.vehicles)
+
+	return vehicles
+}
+
+func (vs *VehicleService) GetVehicle(orgaNo string, phoneNo string) *domain.Vehicle {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	return vs.getVehicle(orgaNo, phoneNo)
+}
+
+func (vs *VehicleService) getVehicle(orgaNo string, phoneNo string) *domain.Vehicle {
+	for _, value := range vs.vehicles {
+		if value.OrgaNo == orgaNo && value.VehiclePhoneNo == phoneNo {
+			return value
+		}
+	}
+
+	return nil
+}
+
+func (vs *VehicleService) GetOrCreateVehicle(vd domain.VehicleDevice) *domain.Vehicle {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	return vs.getOrCreateVehicle(vd)
+}
+
+func (vs *VehicleService) getOrCreateVehicle(vd domain.VehicleDevice) *domain.Vehicle {
+	v := vs.getVehicle(vd.OrgaNo, vd.VehiclePhoneNo)
+
+	if v == nil {
+		v = domain.NewVehicle(vs.env.Random, vd)
+		vs.vehicles = append(vs.vehicles, v)
+
+		fmt.Println("New vehicle registered:")
+		fmt.Println(v)
+	}
+
+	return v
+}
+
+// UpdateVehicle copies the editable fields of v to the stored vehicle, the identity, the source number and
+// the ignition, which the trips drive, are kept
+func (vs *VehicleService) UpdateVehicle(v *domain.Vehicle) *domain.Vehicle {
+	vs.mutex.Lock()
+	defer vs.mutex.Unlock()
+
+	existingVehicle := vs.getOrCreateVehicle(v.VehicleDevice)
+
+	existingVehicle.Odometer = v.Odometer
+	existingVehicle.Fuel = v.Fuel
+	existingVehicle.Latitude = v.Latitude
+	existingVehicle.Longitude = v.Longitude
+	existingVehicle.Locked = v.Locked
+	existingVehicle.Immobilized = v.Immobilized
+	existingVehicle.DoorOpen = v.DoorOpen
+	existingVehicle.TripOptions = v.TripOptions
+	existingVehicle.InterfaceVersion = v.InterfaceVersion
+
+	fmt.Println("Vehicle updated:")
+	fmt.Println(existingVehicle)
+
+	return existingVehicle
+}